@@ -1,6 +1,7 @@
 package bot
 
 import (
+	"DailyDoseBot/internal/callback"
 	"DailyDoseBot/internal/config"
 	"DailyDoseBot/internal/handlers"
 	"time"
@@ -11,7 +12,10 @@ import (
 
 func BotInit(cfg *config.Config, log *zap.Logger) {
 
-	handlers.InitHandlers()
+	if callback.Init(cfg.CallbackSecret) {
+		log.Warn("CALLBACK_SECRET не задан, сгенерирован временный ключ: старые кнопки перестанут работать после перезапуска")
+	}
+	handlers.InitHandlers(cfg)
 	pref := tele.Settings{
 		Token:  cfg.TGtoken,
 		Poller: &tele.LongPoller{Timeout: 10 * time.Second},
@@ -23,12 +27,15 @@ func BotInit(cfg *config.Config, log *zap.Logger) {
 		return
 	}
 
-	// Временная команда для теста debug-статистики (только для себя)
-	b.Handle("/debugstats", func(c tele.Context) error {
-		userID := c.Sender().ID
-		handlers.SendDebugStats(b, userID)
-		return nil
-	})
+	// Диагностика только для администраторов из ADMIN_IDS
+	if len(cfg.AdminIDs) == 0 {
+		log.Warn("ADMIN_IDS не задан, команды /admin недоступны")
+	}
+	admin := b.Group()
+	admin.Use(handlers.AdminOnly(log))
+	admin.Handle("/admin", handlers.AdminHandler(b, log))
+	admin.Handle("/broadcast", handlers.BroadcastHandler(b, log))
+	admin.Handle(&tele.Btn{Unique: "broadcast"}, handlers.HandleBroadcastCallback(b, log))
 
 	b.Handle("/hello", func(c tele.Context) error {
 		return c.Send("Hello!")
@@ -38,6 +45,8 @@ func BotInit(cfg *config.Config, log *zap.Logger) {
 
 	b.Handle("/add", handlers.AddHandler(b, log))
 	b.Handle("➕ Добавить", handlers.AddHandler(b, log))
+	b.Handle("/bulk", handlers.BulkHandler(b, log))
+	b.Handle(&tele.Btn{Unique: "bulk"}, handlers.HandleBulkCallback(b, log))
 
 	b.Handle("📃 Список", handlers.ListHandler(b, log))
 	b.Handle("/list", handlers.ListHandler(b, log))
@@ -63,12 +72,66 @@ func BotInit(cfg *config.Config, log *zap.Logger) {
 	b.Handle("📊 Лог", handlers.LogHandler(b, log))
 	b.Handle("/status", handlers.StatusHandler(b))
 	b.Handle("📊 Статус", handlers.StatusHandler(b))
-	// Кнопка для ручной отметки приёма из /log
-	b.Handle(&tele.Btn{Unique: "intake_accept_log"}, handlers.HandleIntakeAcceptLogCallback(b, log))
-
-	b.Handle(tele.OnText, handlers.AddTextHandler(b, log))
+	// Кнопки /log: навигация по дням, выбор приёма и отметка статуса
+	b.Handle(&tele.Btn{Unique: "log_day"}, handlers.HandleLogDayCallback(b, log))
+	b.Handle(&tele.Btn{Unique: "log_dose"}, handlers.HandleLogDoseCallback(b, log))
+	b.Handle(&tele.Btn{Unique: "log_set"}, handlers.HandleIntakeAcceptLogCallback(b, log))
+
+	b.Handle(&tele.Btn{Unique: "taken_at"}, handlers.HandleIntakeOtherTimeCallback(b, log))
+	b.Handle("/timing", handlers.TimingHandler(b, log))
+
+	// Заметки и побочные эффекты
+	b.Handle(&tele.Btn{Unique: "note"}, handlers.HandleNoteCallback(b, log))
+	b.Handle(&tele.Btn{Unique: "note_tag"}, handlers.HandleNoteTagCallback(b, log))
+	b.Handle(&tele.Btn{Unique: "note_text"}, handlers.HandleNoteTextCallback(b, log))
+	b.Handle(&tele.Btn{Unique: "note_done"}, handlers.HandleNoteDoneCallback(b, log))
+	b.Handle(&tele.Btn{Unique: "journal"}, handlers.HandleJournalCallback(b, log))
+	b.Handle("/journal", handlers.JournalHandler(b, log))
+
+	// Статистика за выбранный период
+	b.Handle("/stats", handlers.StatsHandler(b, log))
+	b.Handle(&tele.Btn{Unique: "stats"}, handlers.HandleStatsCallback(b, log))
+	b.Handle(&tele.Btn{Unique: "stats_custom"}, handlers.HandleStatsCustomCallback(b, log))
+	b.Handle(&tele.Btn{Unique: "stats_map"}, handlers.HandleStatsHeatmapCallback(b, log))
+	b.Handle(&tele.Btn{Unique: "stats_supp"}, handlers.HandleStatsSupplementsCallback(b, log))
+
+	// Достижения и заморозка серии
+	b.Handle("/achievements", handlers.AchievementsHandler(b, log))
+	b.Handle(&tele.Btn{Unique: "ach_freeze"}, handlers.HandleStreakFreezeCallback(b, log))
+
+	// Расписание недельного и месячного отчётов
+	b.Handle("/report", handlers.ReportSettingsHandler(b, log))
+	b.Handle("/analysis", handlers.AnalysisHandler(b, log))
+	b.Handle(&tele.Btn{Unique: "report"}, handlers.HandleReportCallback(b, log))
+
+	// Выгрузка данных
+	b.Handle("/export", handlers.ExportHandler(b, log))
+	b.Handle(&tele.Btn{Unique: "export"}, handlers.HandleExportCallback(b, log))
+	b.Handle("/export_all", handlers.ExportAllHandler(b, log))
+	b.Handle("/import", handlers.ImportHandler(b, log))
+	b.Handle(&tele.Btn{Unique: "import"}, handlers.HandleImportCallback(b, log))
+	b.Handle(tele.OnDocument, handlers.DocumentHandler(b, log))
+
+	// Отчёт для врача
+	b.Handle("/doctor", handlers.DoctorHandler(b, log))
+	b.Handle(&tele.Btn{Unique: "doctor"}, handlers.HandleDoctorCallback(b, log))
+
+	// Расписание в календаре
+	b.Handle("/calendar", handlers.CalendarHandler(b, log))
+	b.Handle(&tele.Btn{Unique: "calendar"}, handlers.HandleCalendarCallback(b, log))
+	handlers.StartCalendarFeed(log)
+
+	// Удаление аккаунта
+	b.Handle("/deleteme", handlers.DeleteMeHandler(b, log))
+	b.Handle(&tele.Btn{Unique: "deleteme"}, handlers.HandleDeleteMeCallback(b, log))
+
+	b.Handle(tele.OnText, handlers.TextHandler(b, log))
 	handlers.RegisterListCallbacks(b, log)
 	b.Handle(&tele.Btn{Unique: "intake_accept"}, handlers.HandleIntakeAcceptCallback(b, log))
+	b.Handle(&tele.Btn{Unique: "intake_undo"}, handlers.HandleIntakeUndoCallback(b, log))
+	b.Handle(&tele.Btn{Unique: "intake_part"}, handlers.HandleIntakePartialCallback(b, log))
+	b.Handle(&tele.Btn{Unique: "prn_take"}, handlers.HandlePRNTakeCallback(b, log))
+	b.Handle("/took", handlers.TookHandler(b, log))
 	handlers.StartNotifier(b, log)
 
 	log.Info("Bot started")
@@ -0,0 +1,106 @@
+package callback
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	tele "gopkg.in/telebot.v4"
+)
+
+// Схема данных inline-кнопок: "<версия>|<поле>|<поле>...|<подпись>".
+// Telegram ограничивает callback_data 64 байтами вместе с префиксом "\f<unique>|",
+// поэтому в полях передаются только короткие токены (UUID в base64, время, дата),
+// а не названия добавок. Подпись — усечённый HMAC-SHA256 от unique и полей,
+// чтобы данные кнопки нельзя было подделать.
+const (
+	Version = "2"
+	MaxLen  = 64
+	sigLen  = 6 // байт HMAC, 8 символов base64
+)
+
+var (
+	ErrOutdated  = errors.New("callback: устаревший формат кнопки")
+	ErrMalformed = errors.New("callback: некорректные данные кнопки")
+	ErrForged    = errors.New("callback: неверная подпись кнопки")
+
+	secret []byte
+)
+
+// Init задаёт ключ подписи. Без ключа генерируется случайный,
+// и кнопки из сообщений до перезапуска бота перестанут работать.
+func Init(key string) (generated bool) {
+	if key != "" {
+		secret = []byte(key)
+		return false
+	}
+	secret = make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic("callback: не удалось сгенерировать ключ: " + err.Error())
+	}
+	return true
+}
+
+func sign(unique, data string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unique + "|" + data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:sigLen])
+}
+
+// Encode собирает подписанные данные кнопки текущей версии схемы.
+// Превышение лимита Telegram — ошибка программиста, поэтому здесь паника.
+func Encode(unique string, fields ...string) string {
+	data := strings.Join(append([]string{Version}, fields...), "|")
+	data += "|" + sign(unique, data)
+	if n := len("\f" + unique + "|" + data); n > MaxLen {
+		panic(fmt.Sprintf("callback: данные кнопки %q занимают %d байт (лимит %d)", unique, n, MaxLen))
+	}
+	return data
+}
+
+// Button создаёт inline-кнопку с данными в формате Encode
+func Button(markup *tele.ReplyMarkup, text, unique string, fields ...string) tele.Btn {
+	return markup.Data(text, unique, Encode(unique, fields...))
+}
+
+// Decode проверяет версию и подпись и возвращает ровно n полей
+func Decode(cb *tele.Callback, n int) ([]string, error) {
+	if cb == nil {
+		return nil, ErrMalformed
+	}
+	parts := strings.Split(cb.Data, "|")
+	if parts[0] != Version {
+		return nil, ErrOutdated
+	}
+	if len(parts)-2 != n {
+		return nil, ErrMalformed
+	}
+	data := strings.Join(parts[:len(parts)-1], "|")
+	if !hmac.Equal([]byte(parts[len(parts)-1]), []byte(sign(cb.Unique, data))) {
+		return nil, ErrForged
+	}
+	return parts[1 : len(parts)-1], nil
+}
+
+// ID кодирует UUID в 22 символа base64url
+func ID(id uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString(id[:])
+}
+
+// ParseID — обратное преобразование для ID
+func ParseID(s string) (uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return uuid.Nil, ErrMalformed
+	}
+	id, err := uuid.FromBytes(raw)
+	if err != nil {
+		return uuid.Nil, ErrMalformed
+	}
+	return id, nil
+}
@@ -2,14 +2,22 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
 )
 
 type Config struct {
-	DB      DBConfig
-	TGtoken string
+	DB             DBConfig
+	TGtoken        string
+	CallbackSecret string  // ключ HMAC для подписи данных inline-кнопок
+	LogLookback    int     // на сколько дней назад можно отмечать приёмы в /log
+	OnTimeWindow   int     // допуск в минутах, при котором приём считается своевременным
+	AdminIDs       []int64 // Telegram ID администраторов, которым доступны команды /admin
+	CalendarAddr   string  // адрес HTTP-сервера для подписки на календарь, например ":8080"; пусто — выключен
+	CalendarURL    string  // внешний адрес этого сервера для ссылок подписки, например "https://bot.example.com"; обязателен вместе с CalendarAddr
 }
 
 type DBConfig struct {
@@ -35,7 +43,13 @@ func Load(log *zap.Logger) *Config {
 			Name:     getEnv("DB_NAME", log),
 			SSLMode:  getEnv("DB_SSLMODE", log),
 		},
-		TGtoken: getEnv("TG_TOKEN", log),
+		TGtoken:        getEnv("TG_TOKEN", log),
+		CallbackSecret: getEnvDefault("CALLBACK_SECRET", ""),
+		LogLookback:    getEnvInt("LOG_LOOKBACK_DAYS", 7, log),
+		OnTimeWindow:   getEnvInt("ON_TIME_WINDOW_MINUTES", 30, log),
+		AdminIDs:       getEnvIDs("ADMIN_IDS", log),
+		CalendarAddr:   getEnvDefault("CALENDAR_ADDR", ""),
+		CalendarURL:    strings.TrimSuffix(getEnvDefault("CALENDAR_URL", ""), "/"),
 	}
 }
 
@@ -47,6 +61,48 @@ func getEnv(key string, log *zap.Logger) string {
 	panic("missing required environment variable: " + key)
 }
 
+func getEnvDefault(key, def string) string {
+	if val, exists := os.LookupEnv(key); exists {
+		return val
+	}
+	return def
+}
+
+func getEnvInt(key string, def int, log *zap.Logger) int {
+	val, exists := os.LookupEnv(key)
+	if !exists {
+		return def
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		log.Warn("Некорректное числовое значение, используется значение по умолчанию", zap.String("key", key), zap.Int("default", def))
+		return def
+	}
+	return n
+}
+
+// Список Telegram ID через запятую; некорректные значения пропускаются
+func getEnvIDs(key string, log *zap.Logger) []int64 {
+	val, exists := os.LookupEnv(key)
+	if !exists {
+		return nil
+	}
+	var ids []int64
+	for _, part := range strings.Split(val, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			log.Warn("Некорректный Telegram ID в списке, пропускаем", zap.String("key", key), zap.String("value", part))
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
 // func parseDuration(s string, log *zap.Logger) time.Duration {
 // 	if strings.HasSuffix(s, "d") {
 // 		daysStr := strings.TrimSuffix(s, "d")
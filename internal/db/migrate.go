@@ -2,6 +2,7 @@ package db
 
 import (
 	"DailyDoseBot/internal/models"
+	"fmt"
 	"os"
 
 	"go.uber.org/zap"
@@ -12,16 +13,65 @@ func Migrate(log *zap.Logger) {
 		log.Error("failed to enable uuid-ossp", zap.Error(err))
 	}
 
-	// Миграция поля IntakeTime для IntakeLog
-	if err := DB.AutoMigrate(&models.User{}, &models.Supplement{}, &models.IntakeLog{}); err != nil {
+	// Уникальный индекс на отметку приёма не создастся, пока в таблице есть дубликаты;
+	// в старых базах колонки intake_time ещё нет, без неё не по чему искать дубликаты
+	if DB.Migrator().HasTable(&models.IntakeLog{}) {
+		if err := DB.Exec(`ALTER TABLE intake_logs ADD COLUMN IF NOT EXISTS intake_time VARCHAR(32) NOT NULL DEFAULT ''`).Error; err != nil {
+			log.Error("Ошибка при добавлении intake_time", zap.Error(err))
+		}
+		dedupIntakeLogs(log)
+	}
+
+	deleteOrphans(log)
+
+	if err := DB.AutoMigrate(&models.User{}, &models.Supplement{}, &models.IntakeLog{}, &models.IntakeLogAudit{}, &models.Achievement{}, &models.StreakFreeze{}); err != nil {
 		log.Error("Ошибка при миграции таблиц", zap.Error(err))
 		os.Exit(1)
 	}
 
-	// Добавим поле IntakeTime, если его нет (для совместимости)
-	if err := DB.Exec(`ALTER TABLE intake_logs ADD COLUMN IF NOT EXISTS intake_time VARCHAR(32) NOT NULL DEFAULT ''`).Error; err != nil {
-		log.Error("Ошибка при добавлении intake_time", zap.Error(err))
+	log.Info("Автомиграция таблиц завершена успешно")
+}
+
+// Удаляет дубликаты отметок по (supplement_id, intake_date, intake_time):
+// из каждой группы остаётся отмеченный приём, а среди равных — самая свежая запись
+func dedupIntakeLogs(log *zap.Logger) {
+	res := DB.Exec(`DELETE FROM intake_logs a USING (
+		SELECT id, ROW_NUMBER() OVER (
+			PARTITION BY supplement_id, intake_date, intake_time
+			ORDER BY taken DESC, created_at DESC, id
+		) AS rn FROM intake_logs
+	) d WHERE a.id = d.id AND d.rn > 1`)
+	if res.Error != nil {
+		log.Error("Ошибка при удалении дубликатов отметок", zap.Error(res.Error))
+		os.Exit(1)
 	}
+	if res.RowsAffected > 0 {
+		log.Info("Удалены дубликаты отметок о приёме", zap.Int64("count", res.RowsAffected))
+	}
+}
 
-	log.Info("Автомиграция таблиц завершена успешно")
+// Строки, ссылающиеся на удалённые записи: без них можно создать внешние ключи с каскадным удалением
+var orphanRefs = []struct{ Table, Column, Parent string }{
+	{"intake_log_audits", "user_id", "users"},
+	{"intake_log_audits", "supplement_id", "supplements"},
+	{"achievements", "user_id", "users"},
+	{"streak_freezes", "user_id", "users"},
+}
+
+// Удаляет строки, оставшиеся от удалённых пользователей и добавок, пока таблицы не были связаны ключами;
+// иначе AutoMigrate не создаст внешние ключи
+func deleteOrphans(log *zap.Logger) {
+	for _, ref := range orphanRefs {
+		if !DB.Migrator().HasTable(ref.Table) || !DB.Migrator().HasTable(ref.Parent) {
+			continue
+		}
+		res := DB.Exec(fmt.Sprintf(`DELETE FROM %s c WHERE NOT EXISTS (SELECT 1 FROM %s p WHERE p.id = c.%s)`, ref.Table, ref.Parent, ref.Column))
+		if res.Error != nil {
+			log.Error("Ошибка при удалении осиротевших записей", zap.String("table", ref.Table), zap.Error(res.Error))
+			os.Exit(1)
+		}
+		if res.RowsAffected > 0 {
+			log.Info("Удалены записи удалённых пользователей и добавок", zap.String("table", ref.Table), zap.Int64("count", res.RowsAffected))
+		}
+	}
 }
@@ -0,0 +1,151 @@
+package doctor
+
+import (
+	"html/template"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Отчёт о приёме добавок для визита к врачу: один HTML-файл без внешних ресурсов,
+// который открывается в любом браузере и печатается на A4.
+
+// Данные отчёта за период; считаются вызывающим кодом
+type Report struct {
+	Patient     string
+	From, To    time.Time
+	Generated   time.Time
+	Supplements []Supplement
+	Days        []Day // все дни периода по порядку
+	Notes       []Note
+	SideEffects []SideEffect
+}
+
+// Добавка с курсом и соблюдением за период
+type Supplement struct {
+	Name     string
+	Dosage   string
+	Schedule string // дни и время приёма
+	WithFood bool
+	Start    time.Time
+	End      *time.Time
+	Status   string
+	PRN      bool
+	Planned  int     // запланировано доз за период
+	Done     float64 // принято доз с учётом частичных
+	Taken    int     // для приёма по необходимости — сколько раз принято
+}
+
+// Выполнение плана за день
+type Day struct {
+	Date    time.Time
+	Planned int
+	Done    float64
+}
+
+// Заметка или побочный эффект к приёму
+type Note struct {
+	Date        time.Time
+	Supplement  string
+	Time        string
+	Text        string
+	SideEffects []string
+}
+
+// Сколько раз отмечен побочный эффект
+type SideEffect struct {
+	Label string
+	Count int
+}
+
+// Клетка календаря; пустая — день вне периода
+type calendarCell struct {
+	Day      *Day
+	DayOfMon int
+}
+
+type calendarMonth struct {
+	Title string
+	Weeks [][7]calendarCell
+}
+
+var monthNamesRu = []string{"Январь", "Февраль", "Март", "Апрель", "Май", "Июнь", "Июль", "Август", "Сентябрь", "Октябрь", "Ноябрь", "Декабрь"}
+
+func percent(done float64, planned int) int {
+	if planned == 0 {
+		return 0
+	}
+	return int(done / float64(planned) * 100)
+}
+
+// Цвет клетки как в календаре /stats: красный → жёлтый → зелёный
+func dayColor(d *Day) string {
+	switch p := percent(d.Done, d.Planned); {
+	case d.Planned == 0:
+		return "#eeeeee"
+	case p >= 100:
+		return "#66bb6a"
+	case p >= 50:
+		return "#ffd54f"
+	default:
+		return "#e57373"
+	}
+}
+
+// Раскладывает дни периода по месяцам и неделям Пн–Вс
+func (r Report) Months() []calendarMonth {
+	var result []calendarMonth
+	for i := range r.Days {
+		d := &r.Days[i]
+		if len(result) == 0 || d.Date.Day() == 1 {
+			result = append(result, calendarMonth{Title: monthNamesRu[d.Date.Month()-1] + " " + d.Date.Format("2006")})
+		}
+		m := &result[len(result)-1]
+		weekday := (int(d.Date.Weekday()) + 6) % 7 // Пн=0
+		if len(m.Weeks) == 0 || weekday == 0 {
+			m.Weeks = append(m.Weeks, [7]calendarCell{})
+		}
+		m.Weeks[len(m.Weeks)-1][weekday] = calendarCell{Day: d, DayOfMon: d.Date.Day()}
+	}
+	return result
+}
+
+// Запланировано доз за весь период
+func (r Report) Planned() int {
+	planned := 0
+	for _, d := range r.Days {
+		planned += d.Planned
+	}
+	return planned
+}
+
+// Принято доз за весь период с учётом частичных
+func (r Report) Done() float64 {
+	done := 0.0
+	for _, d := range r.Days {
+		done += d.Done
+	}
+	return done
+}
+
+var funcs = template.FuncMap{
+	"date": func(t time.Time) string { return t.Format("02.01.2006") },
+	"percent": func(done float64, planned int) int {
+		return percent(done, planned)
+	},
+	"color": dayColor,
+	"dose":  formatDose,
+}
+
+// Количество доз без лишних нулей, с точностью до десятой: 12 или 11.5
+func formatDose(f float64) string {
+	return strconv.FormatFloat(math.Round(f*10)/10, 'f', -1, 64)
+}
+
+var page = template.Must(template.New("report").Funcs(funcs).Parse(reportTemplate))
+
+// Пишет отчёт в HTML; все пользовательские строки экранируются шаблоном
+func Render(w io.Writer, r Report) error {
+	return page.Execute(w, r)
+}
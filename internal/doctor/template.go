@@ -0,0 +1,102 @@
+package doctor
+
+// Разметка отчёта: стили встроены, чтобы файл открывался без сети и печатался как есть
+const reportTemplate = `<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Приём добавок {{date .From}} — {{date .To}}</title>
+<style>
+  body { font-family: -apple-system, "Segoe UI", Roboto, Arial, sans-serif; color: #222; max-width: 960px; margin: 24px auto; padding: 0 16px; font-size: 14px; }
+  h1 { font-size: 22px; margin-bottom: 4px; }
+  h2 { font-size: 17px; margin-top: 28px; border-bottom: 1px solid #ccc; padding-bottom: 4px; }
+  .meta { color: #666; }
+  .summary { font-size: 16px; margin: 12px 0; }
+  table { border-collapse: collapse; width: 100%; }
+  th, td { border: 1px solid #ccc; padding: 5px 7px; text-align: left; vertical-align: top; }
+  th { background: #f3f3f3; }
+  td.num { text-align: right; white-space: nowrap; }
+  .months { display: flex; flex-wrap: wrap; gap: 16px; }
+  .month { break-inside: avoid; }
+  .month table { width: auto; }
+  .month td, .month th { width: 34px; height: 30px; text-align: center; padding: 2px; font-size: 12px; }
+  .month td small { display: block; font-size: 9px; color: #333; }
+  .legend span { display: inline-block; width: 12px; height: 12px; vertical-align: middle; margin: 0 4px 0 12px; border: 1px solid #bbb; }
+  .muted { color: #888; }
+  @media print { body { margin: 0; max-width: none; } h2 { break-after: avoid; } tr { break-inside: avoid; } }
+</style>
+</head>
+<body>
+<h1>Отчёт о приёме добавок</h1>
+<div class="meta">{{if .Patient}}{{.Patient}} · {{end}}период {{date .From}} — {{date .To}} · сформирован {{date .Generated}}</div>
+{{$planned := .Planned}}{{$done := .Done}}
+<div class="summary">
+{{if $planned}}Выполнение плана за период: <b>{{percent $done $planned}}%</b> ({{dose $done}} из {{$planned}} запланированных доз){{else}}За период не было запланированных приёмов.{{end}}
+</div>
+
+<h2>Добавки</h2>
+{{if .Supplements}}
+<table>
+<tr><th>Добавка</th><th>Дозировка</th><th>Расписание</th><th>Курс</th><th>Статус</th><th>Соблюдение</th></tr>
+{{range .Supplements}}
+<tr>
+  <td>{{.Name}}</td>
+  <td>{{.Dosage}}{{if .WithFood}}<br><span class="muted">с едой</span>{{end}}</td>
+  <td>{{.Schedule}}</td>
+  <td class="num">{{date .Start}} — {{if .End}}{{date .End}}{{else}}бессрочно{{end}}</td>
+  <td>{{.Status}}</td>
+  <td class="num">{{if .PRN}}приёмов: {{.Taken}}{{else if .Planned}}{{percent .Done .Planned}}% ({{dose .Done}}/{{.Planned}}){{else}}<span class="muted">—</span>{{end}}</td>
+</tr>
+{{end}}
+</table>
+{{else}}
+<p class="muted">В этот период добавки не принимались.</p>
+{{end}}
+
+<h2>Календарь</h2>
+<div class="legend">Доля принятых доз за день:<span style="background:#66bb6a"></span>все<span style="background:#ffd54f"></span>половина и больше<span style="background:#e57373"></span>меньше половины<span style="background:#eeeeee"></span>приёмов не было</div>
+<div class="months">
+{{range .Months}}
+<div class="month">
+<h3>{{.Title}}</h3>
+<table>
+<tr><th>Пн</th><th>Вт</th><th>Ср</th><th>Чт</th><th>Пт</th><th>Сб</th><th>Вс</th></tr>
+{{range .Weeks}}
+<tr>{{range .}}{{if .Day}}<td style="background:{{color .Day}}">{{.DayOfMon}}{{if .Day.Planned}}<small>{{dose .Day.Done}}/{{.Day.Planned}}</small>{{end}}</td>{{else}}<td></td>{{end}}{{end}}</tr>
+{{end}}
+</table>
+</div>
+{{end}}
+</div>
+
+<h2>Побочные эффекты</h2>
+{{if .SideEffects}}
+<table>
+<tr><th>Эффект</th><th>Сколько раз отмечен</th></tr>
+{{range .SideEffects}}<tr><td>{{.Label}}</td><td class="num">{{.Count}}</td></tr>
+{{end}}
+</table>
+{{else}}
+<p class="muted">Побочные эффекты не отмечались.</p>
+{{end}}
+
+<h2>Заметки</h2>
+{{if .Notes}}
+<table>
+<tr><th>Дата</th><th>Добавка</th><th>Заметка</th><th>Побочные эффекты</th></tr>
+{{range .Notes}}
+<tr>
+  <td class="num">{{date .Date}}{{if .Time}} {{.Time}}{{end}}</td>
+  <td>{{.Supplement}}</td>
+  <td>{{.Text}}</td>
+  <td>{{range $i, $e := .SideEffects}}{{if $i}}, {{end}}{{$e}}{{end}}</td>
+</tr>
+{{end}}
+</table>
+{{else}}
+<p class="muted">Заметок за период нет.</p>
+{{end}}
+</body>
+</html>
+`
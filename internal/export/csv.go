@@ -0,0 +1,178 @@
+package export
+
+import (
+	"DailyDoseBot/internal/models"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Выгрузка данных пользователя в CSV по RFC 4180: запятая, CRLF, кавычки по необходимости.
+// Названия колонок — часть формата: их читают таблицы и скрипты пользователей,
+// поэтому колонки только добавляются в конец и не переименовываются.
+
+// Форматы дат и времени в выгрузке
+const (
+	DateFormat     = "2006-01-02"
+	DateTimeFormat = time.RFC3339
+)
+
+// Разделитель значений внутри одной ячейки (дни недели, времена, побочные эффекты)
+const listSeparator = ";"
+
+// Статусы отметки о приёме
+const (
+	StatusTaken    = "taken"
+	StatusPartial  = "partial"
+	StatusSkipped  = "skipped"
+	StatusNotTaken = "not_taken"
+)
+
+// Дни недели в нумерации бота: Пн=0 ... Вс=6
+var weekdayCodes = []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}
+
+// Колонки истории приёмов
+var IntakeColumns = []string{
+	"date", "time", "supplement", "dosage", "status",
+	"quantity_taken", "quantity_planned", "taken_at", "backfilled", "note", "side_effects",
+}
+
+// Колонки списка добавок
+var SupplementColumns = []string{
+	"id", "name", "dosage", "intake_time", "with_food", "schedule_type", "days_of_week",
+	"reminder_times", "reminders_enabled", "start_date", "end_date", "completed", "max_daily_doses",
+}
+
+// Строка истории: отметка о приёме и добавка, к которой она относится
+type IntakeRow struct {
+	Log        models.IntakeLog
+	Supplement models.Supplement
+}
+
+// Статус отметки для выгрузки
+func Status(entry models.IntakeLog) string {
+	switch {
+	case entry.IsPartial():
+		return StatusPartial
+	case entry.Taken:
+		return StatusTaken
+	case entry.Skipped:
+		return StatusSkipped
+	default:
+		return StatusNotTaken
+	}
+}
+
+// Пишет историю приёмов в порядке rows
+func WriteIntakes(w io.Writer, rows []IntakeRow) error {
+	cw := newWriter(w)
+	if err := cw.Write(IntakeColumns); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		takenAt := ""
+		if r.Log.TakenAt != nil {
+			takenAt = r.Log.TakenAt.Format(DateTimeFormat)
+		}
+		record := []string{
+			r.Log.IntakeDate.UTC().Format(DateFormat),
+			r.Log.IntakeTime,
+			r.Supplement.Name,
+			r.Supplement.Dosage,
+			Status(r.Log),
+			formatQuantity(r.Log.QuantityTaken),
+			formatQuantity(r.Log.QuantityPlanned),
+			takenAt,
+			strconv.FormatBool(r.Log.Backfilled),
+			r.Log.Note,
+			strings.Join(decodeStrings(r.Log.SideEffects), listSeparator),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Пишет добавки со всеми полями расписания
+func WriteSupplements(w io.Writer, supplements []models.Supplement) error {
+	cw := newWriter(w)
+	if err := cw.Write(SupplementColumns); err != nil {
+		return err
+	}
+	for _, s := range supplements {
+		endDate := ""
+		if s.EndDate != nil {
+			endDate = s.EndDate.UTC().Format(DateFormat)
+		}
+		record := []string{
+			s.ID.String(),
+			s.Name,
+			s.Dosage,
+			s.IntakeTime,
+			strconv.FormatBool(s.WithFood),
+			scheduleType(s),
+			strings.Join(weekdays(s), listSeparator),
+			strings.Join(decodeStrings(s.ReminderTimes), listSeparator),
+			strconv.FormatBool(s.ReminderEnabled),
+			s.StartDate.UTC().Format(DateFormat),
+			endDate,
+			strconv.FormatBool(s.Completed),
+			strconv.Itoa(s.MaxDailyDoses),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func newWriter(w io.Writer) *csv.Writer {
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+	return cw
+}
+
+// Старые записи могли сохраниться без типа расписания
+func scheduleType(s models.Supplement) string {
+	if s.ScheduleType == "" {
+		return models.ScheduleRegular
+	}
+	return s.ScheduleType
+}
+
+// Дни недели добавки кодами mon..sun; пусто — каждый день
+func weekdays(s models.Supplement) []string {
+	var days []int
+	if len(s.DaysOfWeek) > 2 {
+		_ = json.Unmarshal([]byte(s.DaysOfWeek), &days)
+	}
+	codes := make([]string, 0, len(days))
+	for _, d := range days {
+		if d >= 0 && d < len(weekdayCodes) {
+			codes = append(codes, weekdayCodes[d])
+		}
+	}
+	return codes
+}
+
+func decodeStrings(raw []byte) []string {
+	var values []string
+	if len(raw) > 2 {
+		_ = json.Unmarshal(raw, &values)
+	}
+	return values
+}
+
+// Количество без лишних нулей; 0 — доза не измерялась, ячейка пустая
+func formatQuantity(q float64) string {
+	if q == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(q, 'f', -1, 64)
+}
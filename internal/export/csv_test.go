@@ -0,0 +1,217 @@
+package export
+
+import (
+	"DailyDoseBot/internal/models"
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+var update = flag.Bool("update", false, "перезаписать эталонные файлы в testdata")
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// Сравнивает вывод с эталоном побайтно: важны и кавычки, и CRLF
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	golden := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("нет эталона %s (запусти go test -update): %v", golden, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s не совпадает с эталоном\n got: %q\nwant: %q", name, got, want)
+	}
+}
+
+func sampleSupplements() []models.Supplement {
+	end := date(2026, 12, 31)
+	return []models.Supplement{
+		{
+			ID:              uuid.MustParse("6f1c2b1e-0000-4000-8000-000000000001"),
+			Name:            "Витамин D3",
+			Dosage:          "2000 МЕ",
+			IntakeTime:      "morning",
+			WithFood:        true,
+			ScheduleType:    models.ScheduleRegular,
+			DaysOfWeek:      datatypes.JSON(`[0,2,4]`),
+			ReminderTimes:   datatypes.JSON(`["08:00","20:30"]`),
+			ReminderEnabled: true,
+			StartDate:       date(2026, 9, 1),
+			EndDate:         &end,
+		},
+		{
+			// Запятая, кавычки и перенос строки в названии требуют кавычек по RFC 4180; старая запись без типа расписания
+			ID:            uuid.MustParse("6f1c2b1e-0000-4000-8000-000000000002"),
+			Name:          "Омега-3, \"рыбий\" жир\nв капсулах",
+			Dosage:        "1 капсула",
+			IntakeTime:    "any",
+			DaysOfWeek:    datatypes.JSON(`[]`),
+			ReminderTimes: datatypes.JSON(`[]`),
+			StartDate:     date(2026, 10, 1),
+			Completed:     true,
+		},
+		{
+			// По необходимости и с битым JSON дней недели
+			ID:            uuid.MustParse("6f1c2b1e-0000-4000-8000-000000000003"),
+			Name:          "Ибупрофен",
+			Dosage:        "200 мг",
+			IntakeTime:    "any",
+			ScheduleType:  models.SchedulePRN,
+			DaysOfWeek:    datatypes.JSON(`[0,1`),
+			StartDate:     date(2026, 10, 5),
+			MaxDailyDoses: 3,
+		},
+	}
+}
+
+func sampleIntakes() []IntakeRow {
+	supps := sampleSupplements()
+	takenAt := time.Date(2026, 10, 1, 8, 12, 0, 0, time.FixedZone("MSK", 3*60*60))
+	return []IntakeRow{
+		{Supplement: supps[0], Log: models.IntakeLog{IntakeDate: date(2026, 10, 1), IntakeTime: "08:00", Taken: true, TakenAt: &takenAt,
+			SideEffects: datatypes.JSON(`["nausea","headache"]`)}},
+		{Supplement: supps[0], Log: models.IntakeLog{IntakeDate: date(2026, 10, 1), IntakeTime: "20:30", Taken: true,
+			QuantityTaken: 0.5, QuantityPlanned: 2, Backfilled: true, Note: "забыл, принял половину"}},
+		{Supplement: supps[1], Log: models.IntakeLog{IntakeDate: date(2026, 10, 2), Skipped: true,
+			Note: "сказал врач: \"пропустить\"\nна день", SideEffects: datatypes.JSON(`[]`)}},
+		{Supplement: supps[2], Log: models.IntakeLog{IntakeDate: date(2026, 10, 6), IntakeTime: "14:05"}},
+	}
+}
+
+func TestWriteIntakesGolden(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteIntakes(&buf, sampleIntakes()); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "intakes.csv", buf.Bytes())
+}
+
+func TestWriteSupplementsGolden(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSupplements(&buf, sampleSupplements()); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "supplements.csv", buf.Bytes())
+}
+
+// Пустая выгрузка — только строка заголовков
+func TestWriteEmptyGolden(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteIntakes(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "intakes_empty.csv", buf.Bytes())
+	buf.Reset()
+	if err := WriteSupplements(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "supplements_empty.csv", buf.Bytes())
+}
+
+// Выгрузка читается обратно тем же набором значений, построчно с CRLF
+func TestWriteIntakesRoundTrip(t *testing.T) {
+	rows := sampleIntakes()
+	var buf bytes.Buffer
+	if err := WriteIntakes(&buf, rows); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(buf.String(), "\r\n") < len(rows)+1 {
+		t.Errorf("строки должны заканчиваться CRLF: %q", buf.String())
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(records[0], IntakeColumns) {
+		t.Errorf("заголовок = %q, want %q", records[0], IntakeColumns)
+	}
+	if len(records) != len(rows)+1 {
+		t.Fatalf("строк %d, want %d", len(records), len(rows)+1)
+	}
+	if got := records[3][9]; got != rows[2].Log.Note {
+		t.Errorf("заметка = %q, want %q", got, rows[2].Log.Note)
+	}
+}
+
+// Колонки — часть формата: переименование или перестановка ломает чужие скрипты
+func TestColumnsStable(t *testing.T) {
+	wantIntake := []string{"date", "time", "supplement", "dosage", "status",
+		"quantity_taken", "quantity_planned", "taken_at", "backfilled", "note", "side_effects"}
+	if !reflect.DeepEqual(IntakeColumns[:len(wantIntake)], wantIntake) {
+		t.Errorf("IntakeColumns = %q", IntakeColumns)
+	}
+	wantSupplement := []string{"id", "name", "dosage", "intake_time", "with_food", "schedule_type", "days_of_week",
+		"reminder_times", "reminders_enabled", "start_date", "end_date", "completed", "max_daily_doses"}
+	if !reflect.DeepEqual(SupplementColumns[:len(wantSupplement)], wantSupplement) {
+		t.Errorf("SupplementColumns = %q", SupplementColumns)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		log  models.IntakeLog
+		want string
+	}{
+		{models.IntakeLog{Taken: true}, StatusTaken},
+		{models.IntakeLog{Taken: true, QuantityTaken: 2, QuantityPlanned: 2}, StatusTaken},
+		{models.IntakeLog{Taken: true, QuantityTaken: 1, QuantityPlanned: 2}, StatusPartial},
+		{models.IntakeLog{Skipped: true}, StatusSkipped},
+		{models.IntakeLog{}, StatusNotTaken},
+	}
+	for _, tt := range tests {
+		if got := Status(tt.log); got != tt.want {
+			t.Errorf("Status(%+v) = %q, want %q", tt.log, got, tt.want)
+		}
+	}
+}
+
+// Даты хранятся полночью UTC, а из базы приходят в поясе сервера: в выгрузке — календарная дата UTC
+func TestWriteDatesInUTC(t *testing.T) {
+	west := time.FixedZone("UTC-5", -5*60*60)
+	supp := sampleSupplements()[0]
+	supp.StartDate = supp.StartDate.In(west)
+	end := supp.EndDate.In(west)
+	supp.EndDate = &end
+	log := models.IntakeLog{IntakeDate: date(2026, 10, 1).In(west), IntakeTime: "08:00", Taken: true}
+
+	var buf bytes.Buffer
+	if err := WriteIntakes(&buf, []IntakeRow{{Supplement: supp, Log: log}}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := records[1][0]; got != "2026-10-01" {
+		t.Errorf("date = %q, want 2026-10-01", got)
+	}
+
+	buf.Reset()
+	if err := WriteSupplements(&buf, []models.Supplement{supp}); err != nil {
+		t.Fatal(err)
+	}
+	if records, err = csv.NewReader(&buf).ReadAll(); err != nil {
+		t.Fatal(err)
+	}
+	if start, end := records[1][9], records[1][10]; start != "2026-09-01" || end != "2026-12-31" {
+		t.Errorf("start_date, end_date = %q, %q, want 2026-09-01, 2026-12-31", start, end)
+	}
+}
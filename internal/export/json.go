@@ -0,0 +1,275 @@
+package export
+
+import (
+	"DailyDoseBot/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// Полная выгрузка аккаунта в JSON для переноса и восстановления.
+// Документ не содержит внутренних идентификаторов: отметки вложены в свою добавку,
+// поэтому его можно загрузить в любой аккаунт. При несовместимом изменении
+// формата увеличивается Version, а Parse продолжает читать старые версии.
+
+// Признак документа и текущая версия формата
+const (
+	Format  = "dailydose-export"
+	Version = 1
+)
+
+// Максимальная длина названия, заметки и прочих строк при загрузке
+const maxFieldLength = 500
+
+var clockRegex = regexp.MustCompile(`^([01]\d|2[0-3]):[0-5]\d$`)
+
+var (
+	ErrNotExport   = errors.New("это не файл выгрузки DailyDoseBot")
+	ErrNewerFormat = errors.New("файл выгружен более новой версией бота")
+)
+
+type Document struct {
+	Format      string       `json:"format"`
+	Version     int          `json:"version"`
+	ExportedAt  time.Time    `json:"exported_at"`
+	User        UserData     `json:"user"`
+	Supplements []Supplement `json:"supplements"`
+}
+
+// Настройки пользователя
+type UserData struct {
+	Name              string     `json:"name"`
+	CreatedAt         time.Time  `json:"created_at"`
+	Timezone          string     `json:"timezone"`
+	ReportWeekly      bool       `json:"report_weekly"`
+	ReportMonthly     bool       `json:"report_monthly"`
+	ReportWeekday     int        `json:"report_weekday"`
+	ReportTime        string     `json:"report_time"`
+	StreakFreezeSince *time.Time `json:"streak_freeze_since,omitempty"`
+}
+
+// Добавка с расписанием и историей приёмов
+type Supplement struct {
+	Name            string      `json:"name"`
+	Dosage          string      `json:"dosage"`
+	IntakeTime      string      `json:"intake_time"`
+	WithFood        bool        `json:"with_food"`
+	ScheduleType    string      `json:"schedule_type"`
+	DaysOfWeek      []int       `json:"days_of_week"` // Пн=0 ... Вс=6, пусто — каждый день
+	ReminderTimes   []string    `json:"reminder_times"`
+	ReminderEnabled bool        `json:"reminder_enabled"`
+	MaxDailyDoses   int         `json:"max_daily_doses"`
+	StartDate       time.Time   `json:"start_date"`
+	EndDate         *time.Time  `json:"end_date,omitempty"`
+	Completed       bool        `json:"completed"`
+	CreatedAt       time.Time   `json:"created_at"`
+	Intakes         []IntakeLog `json:"intakes"`
+}
+
+// Отметка о приёме
+type IntakeLog struct {
+	Date            time.Time  `json:"date"`
+	Time            string     `json:"time"`
+	Taken           bool       `json:"taken"`
+	Skipped         bool       `json:"skipped"`
+	Backfilled      bool       `json:"backfilled"`
+	TakenAt         *time.Time `json:"taken_at,omitempty"`
+	QuantityTaken   float64    `json:"quantity_taken,omitempty"`
+	QuantityPlanned float64    `json:"quantity_planned,omitempty"`
+	Note            string     `json:"note,omitempty"`
+	SideEffects     []string   `json:"side_effects,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// Собирает документ из данных пользователя; отметки привязываются к добавкам по SupplementID
+func Build(user models.User, supplements []models.Supplement, logs []models.IntakeLog, now time.Time) Document {
+	doc := Document{
+		Format:     Format,
+		Version:    Version,
+		ExportedAt: now,
+		User: UserData{
+			Name:              user.Name,
+			CreatedAt:         user.CreatedAt,
+			Timezone:          user.Timezone,
+			ReportWeekly:      user.ReportWeekly,
+			ReportMonthly:     user.ReportMonthly,
+			ReportWeekday:     user.ReportWeekday,
+			ReportTime:        user.ReportTime,
+			StreakFreezeSince: user.StreakFreezeSince,
+		},
+		Supplements: make([]Supplement, 0, len(supplements)),
+	}
+	index := make(map[uuid.UUID]int, len(supplements))
+	for i, s := range supplements {
+		var days []int
+		if len(s.DaysOfWeek) > 2 {
+			_ = json.Unmarshal([]byte(s.DaysOfWeek), &days)
+		}
+		index[s.ID] = i
+		doc.Supplements = append(doc.Supplements, Supplement{
+			Name:            s.Name,
+			Dosage:          s.Dosage,
+			IntakeTime:      s.IntakeTime,
+			WithFood:        s.WithFood,
+			ScheduleType:    scheduleType(s),
+			DaysOfWeek:      days,
+			ReminderTimes:   decodeStrings(s.ReminderTimes),
+			ReminderEnabled: s.ReminderEnabled,
+			MaxDailyDoses:   s.MaxDailyDoses,
+			StartDate:       s.StartDate,
+			EndDate:         s.EndDate,
+			Completed:       s.Completed,
+			CreatedAt:       s.CreatedAt,
+			Intakes:         []IntakeLog{},
+		})
+	}
+	for _, l := range logs {
+		i, ok := index[l.SupplementID]
+		if !ok {
+			continue
+		}
+		doc.Supplements[i].Intakes = append(doc.Supplements[i].Intakes, IntakeLog{
+			Date:            l.IntakeDate,
+			Time:            l.IntakeTime,
+			Taken:           l.Taken,
+			Skipped:         l.Skipped,
+			Backfilled:      l.Backfilled,
+			TakenAt:         l.TakenAt,
+			QuantityTaken:   l.QuantityTaken,
+			QuantityPlanned: l.QuantityPlanned,
+			Note:            l.Note,
+			SideEffects:     decodeStrings(l.SideEffects),
+			CreatedAt:       l.CreatedAt,
+		})
+	}
+	return doc
+}
+
+// Пишет документ с отступами, чтобы его было удобно читать
+func WriteJSON(w io.Writer, doc Document) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// Читает и проверяет документ выгрузки
+func Parse(r io.Reader) (Document, error) {
+	var doc Document
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return doc, ErrNotExport
+	}
+	if doc.Format != Format || doc.Version < 1 {
+		return doc, ErrNotExport
+	}
+	if doc.Version > Version {
+		return doc, ErrNewerFormat
+	}
+	for i, s := range doc.Supplements {
+		if err := validateSupplement(s); err != nil {
+			return doc, fmt.Errorf("добавка %d (%s): %w", i+1, s.Name, err)
+		}
+	}
+	return doc, nil
+}
+
+func validateSupplement(s Supplement) error {
+	if strings.TrimSpace(s.Name) == "" {
+		return errors.New("пустое название")
+	}
+	if len(s.Name) > maxFieldLength || len(s.Dosage) > maxFieldLength || len(s.IntakeTime) > maxFieldLength {
+		return errors.New("слишком длинное значение")
+	}
+	if s.ScheduleType != models.ScheduleRegular && s.ScheduleType != models.SchedulePRN {
+		return fmt.Errorf("неизвестный тип расписания %q", s.ScheduleType)
+	}
+	for _, d := range s.DaysOfWeek {
+		if d < 0 || d > 6 {
+			return fmt.Errorf("некорректный день недели %d", d)
+		}
+	}
+	for _, t := range s.ReminderTimes {
+		if !clockRegex.MatchString(t) {
+			return fmt.Errorf("некорректное время напоминания %q", t)
+		}
+	}
+	if s.EndDate != nil && s.EndDate.Before(s.StartDate) {
+		return errors.New("дата окончания раньше даты начала")
+	}
+	if s.MaxDailyDoses < 0 {
+		return errors.New("отрицательный лимит приёмов")
+	}
+	for _, l := range s.Intakes {
+		if l.Date.IsZero() {
+			return errors.New("отметка без даты")
+		}
+		// Старые отметки хранят время словами ("morning"), поэтому формат не проверяется
+		if len(l.Time) > maxFieldLength || len(l.Note) > maxFieldLength {
+			return errors.New("слишком длинное значение в отметке")
+		}
+		if l.QuantityTaken < 0 || l.QuantityPlanned < 0 {
+			return errors.New("отрицательное количество")
+		}
+	}
+	return nil
+}
+
+// Модели для загрузки документа в аккаунт userID; повторяющиеся отметки одной дозы отбрасываются.
+// ID назначаются при создании, поэтому SupplementID отметок заполняет вызывающий код
+func (s Supplement) Models(userID uuid.UUID) (models.Supplement, []models.IntakeLog) {
+	supp := models.Supplement{
+		CreatedAt:       s.CreatedAt,
+		UserID:          userID,
+		Name:            strings.TrimSpace(s.Name),
+		Dosage:          s.Dosage,
+		IntakeTime:      s.IntakeTime,
+		WithFood:        s.WithFood,
+		DaysOfWeek:      encodeJSON(s.DaysOfWeek),
+		StartDate:       s.StartDate,
+		EndDate:         s.EndDate,
+		ReminderTimes:   encodeJSON(s.ReminderTimes),
+		ReminderEnabled: s.ReminderEnabled,
+		Completed:       s.Completed,
+		ScheduleType:    s.ScheduleType,
+		MaxDailyDoses:   s.MaxDailyDoses,
+	}
+	var logs []models.IntakeLog
+	seen := make(map[string]bool, len(s.Intakes))
+	for _, l := range s.Intakes {
+		key := l.Date.Format(DateFormat) + "|" + l.Time
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		logs = append(logs, models.IntakeLog{
+			CreatedAt:       l.CreatedAt,
+			UserID:          userID,
+			IntakeDate:      l.Date,
+			IntakeTime:      l.Time,
+			Taken:           l.Taken,
+			Skipped:         l.Skipped,
+			Backfilled:      l.Backfilled,
+			TakenAt:         l.TakenAt,
+			Note:            l.Note,
+			SideEffects:     encodeJSON(l.SideEffects),
+			QuantityTaken:   l.QuantityTaken,
+			QuantityPlanned: l.QuantityPlanned,
+		})
+	}
+	return supp, logs
+}
+
+// Пустой список хранится как "[]", как и у добавок из мастера
+func encodeJSON[T any](values []T) datatypes.JSON {
+	if values == nil {
+		values = []T{}
+	}
+	raw, _ := json.Marshal(values)
+	return datatypes.JSON(raw)
+}
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/callback"
+	"DailyDoseBot/internal/db"
+	"DailyDoseBot/internal/export"
+	"DailyDoseBot/internal/models"
+	"DailyDoseBot/internal/utils"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+	"gorm.io/gorm"
+)
+
+// Максимальный размер загружаемого файла выгрузки
+const maxImportSize = 10 << 20
+
+// Сколько действует кнопка окончательного удаления аккаунта
+const deleteConfirmWindow = 5 * time.Minute
+
+// Шаги удаления аккаунта
+const (
+	deleteCancel  = "0"
+	deleteAsk     = "1" // первое подтверждение
+	deleteConfirm = "2" // окончательное удаление
+)
+
+// Загруженные, но ещё не подтверждённые документы /import
+var importDrafts = struct {
+	sync.Mutex
+	m map[int64]*export.Document
+}{m: make(map[int64]*export.Document)}
+
+// /export_all — полная выгрузка аккаунта в JSON
+func ExportAllHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		var user models.User
+		if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+			return c.Send("Пользователь не найден.")
+		}
+		var supplements []models.Supplement
+		if err := db.DB.Where("user_id = ?", user.ID).Order("created_at").Find(&supplements).Error; err != nil {
+			log.Error("Ошибка выгрузки добавок", zap.Error(err))
+			return c.Send("Ошибка при выгрузке.")
+		}
+		var logs []models.IntakeLog
+		if err := db.DB.Where("user_id = ?", user.ID).Order("intake_date, intake_time").Find(&logs).Error; err != nil {
+			log.Error("Ошибка выгрузки отметок", zap.Error(err))
+			return c.Send("Ошибка при выгрузке.")
+		}
+		var buf bytes.Buffer
+		if err := export.WriteJSON(&buf, export.Build(user, supplements, logs, time.Now())); err != nil {
+			log.Error("Ошибка формирования JSON", zap.Error(err))
+			return c.Send("Ошибка при выгрузке.")
+		}
+		return c.Send(&tele.Document{
+			File:     tele.FromReader(&buf),
+			FileName: "dailydose_account_" + nowDate().Format(logDateFormat) + ".json",
+			MIME:     "application/json",
+			Caption:  fmt.Sprintf("🗄 Полная выгрузка: %d добавок, %d отметок.\nЧтобы восстановить данные, отправь этот файл после команды /import", len(supplements), len(logs)),
+		})
+	}
+}
+
+// /import — ждём файл из /export_all
+func ImportHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		setPendingInput(c.Sender().ID, &PendingInput{Kind: inputImport})
+		return c.Send("📥 Пришли JSON-файл, полученный командой /export_all.\n\nДобавки из файла добавятся к текущим, совпадающие по названию и дате начала будут пропущены.", utils.CancelKeyboard())
+	}
+}
+
+// Скачивает присланный документ, не больше limit байт
+func downloadDocument(b *tele.Bot, doc *tele.Document, limit int64) ([]byte, error) {
+	if doc.FileSize > limit {
+		return nil, fmt.Errorf("файл больше %d МБ", limit>>20)
+	}
+	rc, err := b.File(&doc.File)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("файл больше %d МБ", limit>>20)
+	}
+	return data, nil
+}
+
+// Файл для /import: проверяем и показываем, что будет загружено
+func handleImportDocument(c tele.Context, b *tele.Bot, log *zap.Logger, input *PendingInput) error {
+	data, err := downloadDocument(b, c.Message().Document, maxImportSize)
+	if err != nil {
+		log.Warn("Не удалось скачать файл импорта", zap.Error(err))
+		return c.Send("Не удалось получить файл: "+err.Error(), utils.MainMenuKeyboard())
+	}
+	doc, err := export.Parse(bytes.NewReader(data))
+	if err != nil {
+		setPendingInput(c.Sender().ID, input)
+		return c.Send("Файл не подходит: "+err.Error()+"\n\nПришли другой файл или нажми «❌ Отмена».", utils.CancelKeyboard())
+	}
+	intakes := 0
+	for _, s := range doc.Supplements {
+		intakes += len(s.Intakes)
+	}
+	importDrafts.Lock()
+	importDrafts.m[c.Sender().ID] = &doc
+	importDrafts.Unlock()
+
+	if err := c.Send("Файл проверен ✅", utils.MainMenuKeyboard()); err != nil {
+		return err
+	}
+	markup := &tele.ReplyMarkup{}
+	markup.Inline(markup.Row(
+		callback.Button(markup, "✅ Загрузить", "import", "y"),
+		callback.Button(markup, "❌ Отмена", "import", "n"),
+	))
+	return c.Send(fmt.Sprintf("В файле от %s:\n💊 добавок: %d\n📋 отметок о приёме: %d\n\nЗагрузить в аккаунт? Также будут применены часовой пояс и настройки отчётов.",
+		doc.ExportedAt.Format("02.01.2006"), len(doc.Supplements), intakes), markup)
+}
+
+// Подтверждение /import
+func HandleImportCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		fields, err := callback.Decode(c.Callback(), 1) // y — загрузить, n — отменить
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		importDrafts.Lock()
+		doc, ok := importDrafts.m[c.Sender().ID]
+		delete(importDrafts.m, c.Sender().ID)
+		importDrafts.Unlock()
+		if fields[0] != "y" {
+			_ = c.Respond()
+			return c.Edit("Загрузка отменена.")
+		}
+		if !ok {
+			return c.Respond(&tele.CallbackResponse{Text: "Файл уже загружен или устарел, пришли его заново через /import"})
+		}
+		var user models.User
+		if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Пользователь не найден"})
+		}
+		added, skipped, intakes, err := importDocument(user, *doc)
+		if err != nil {
+			log.Error("Ошибка импорта", zap.Error(err))
+			return c.Respond(&tele.CallbackResponse{Text: "Ошибка при загрузке, данные не изменены"})
+		}
+		_ = c.Respond()
+		msg := fmt.Sprintf("📥 Загружено: %d добавок, %d отметок о приёме.", added, intakes)
+		if skipped > 0 {
+			msg += fmt.Sprintf("\nПропущено уже существующих добавок: %d", skipped)
+		}
+		return c.Edit(msg)
+	}
+}
+
+// Загружает документ одной транзакцией: либо всё, либо ничего
+func importDocument(user models.User, doc export.Document) (added, skipped, intakes int, err error) {
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		added, skipped, intakes = 0, 0, 0
+		var existing []models.Supplement
+		if err := tx.Where("user_id = ?", user.ID).Find(&existing).Error; err != nil {
+			return err
+		}
+		seen := make(map[string]bool, len(existing))
+		for _, s := range existing {
+			seen[s.Name+"|"+s.StartDate.Format(export.DateFormat)] = true
+		}
+		for _, s := range doc.Supplements {
+			supp, logs := s.Models(user.ID)
+			key := supp.Name + "|" + supp.StartDate.Format(export.DateFormat)
+			if seen[key] {
+				skipped++
+				continue
+			}
+			seen[key] = true
+			// Select("*"): иначе выключенные напоминания заменятся значением по умолчанию
+			if err := tx.Select("*").Create(&supp).Error; err != nil {
+				return err
+			}
+			for i := range logs {
+				logs[i].SupplementID = supp.ID
+			}
+			if len(logs) > 0 {
+				if err := tx.CreateInBatches(logs, 500).Error; err != nil {
+					return err
+				}
+			}
+			added++
+			intakes += len(logs)
+		}
+
+		// Настройки переносим, только если они корректны для текущей версии бота
+		settings := doc.User
+		if tz, ok := parseTimezone(settings.Timezone); ok || settings.Timezone == "" {
+			user.Timezone = tz
+		}
+		if clockRegex.MatchString(settings.ReportTime) {
+			user.ReportTime = settings.ReportTime
+		}
+		if settings.ReportWeekday >= 0 && settings.ReportWeekday <= 6 {
+			user.ReportWeekday = settings.ReportWeekday
+		}
+		user.ReportWeekly = settings.ReportWeekly
+		user.ReportMonthly = settings.ReportMonthly
+		if user.StreakFreezeSince == nil && settings.StreakFreezeSince != nil {
+			if err := tx.Model(&user).Update("streak_freeze_since", settings.StreakFreezeSince).Error; err != nil {
+				return err
+			}
+		}
+		return saveReportSettingsTx(tx, &user)
+	})
+	return added, skipped, intakes, err
+}
+
+// /deleteme — удаление аккаунта и всех данных
+func DeleteMeHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		markup := &tele.ReplyMarkup{}
+		markup.Inline(markup.Row(
+			callback.Button(markup, "Да, удалить", "deleteme", deleteAsk, "0"),
+			callback.Button(markup, "Отмена", "deleteme", deleteCancel, "0"),
+		))
+		return c.Send("⚠️ Удалить аккаунт?\n\nБудут безвозвратно удалены все добавки, история приёмов, заметки, достижения и настройки. Сначала можно сохранить данные командой /export_all.", markup)
+	}
+}
+
+// Кнопки /deleteme: второе подтверждение и удаление
+func HandleDeleteMeCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		fields, err := callback.Decode(c.Callback(), 2) // шаг, время выдачи кнопки окончательного удаления
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		switch fields[0] {
+		case deleteCancel:
+			_ = c.Respond()
+			return c.Edit("Удаление отменено. Твои данные на месте 👍")
+		case deleteAsk:
+			markup := &tele.ReplyMarkup{}
+			markup.Inline(markup.Row(
+				callback.Button(markup, "🗑 Удалить навсегда", "deleteme", deleteConfirm, strconv.FormatInt(time.Now().Unix(), 10)),
+				callback.Button(markup, "Отмена", "deleteme", deleteCancel, "0"),
+			))
+			_ = c.Respond()
+			return c.Edit("❗️ Точно удалить? Это действие нельзя отменить.", markup)
+		case deleteConfirm:
+			issued, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return respondBadCallback(c, log, callback.ErrMalformed)
+			}
+			if time.Since(time.Unix(issued, 0)) > deleteConfirmWindow {
+				_ = c.Respond(&tele.CallbackResponse{Text: "Подтверждение устарело"})
+				return c.Edit("Подтверждение устарело. Если всё ещё хочешь удалить аккаунт, отправь /deleteme ещё раз.")
+			}
+			if err := deleteAccount(c.Sender().ID); err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return c.Respond(&tele.CallbackResponse{Text: "Аккаунт уже удалён"})
+				}
+				log.Error("Ошибка удаления аккаунта", zap.Error(err))
+				return c.Respond(&tele.CallbackResponse{Text: "Ошибка при удалении, попробуй позже"})
+			}
+			log.Info("Аккаунт удалён по запросу пользователя", zap.Int64("telegram_id", c.Sender().ID))
+			_ = c.Respond()
+			return c.Edit("Аккаунт и все данные удалены. Если захочешь вернуться — отправь /start.")
+		}
+		return respondBadCallback(c, log, callback.ErrMalformed)
+	}
+}
+
+// Удаляет пользователя; добавки, отметки, журнал изменений, достижения и заморозки
+// удаляются каскадом по внешним ключам
+func deleteAccount(telegramID int64) error {
+	res := db.DB.Where("telegram_id = ?", telegramID).Delete(&models.User{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	// Незавершённые диалоги в памяти больше не относятся ни к какому аккаунту
+	takePendingInput(telegramID)
+	addStates.Lock()
+	delete(addStates.m, telegramID)
+	addStates.Unlock()
+	importDrafts.Lock()
+	delete(importDrafts.m, telegramID)
+	importDrafts.Unlock()
+	bulkDrafts.Lock()
+	delete(bulkDrafts.m, telegramID)
+	bulkDrafts.Unlock()
+	return nil
+}
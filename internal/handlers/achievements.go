@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/db"
+	"DailyDoseBot/internal/models"
+	"DailyDoseBot/internal/schedule"
+	"DailyDoseBot/internal/utils"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+	"gorm.io/gorm/clause"
+)
+
+// Сколько пропусков в месяц может закрыть заморозка серии
+const streakFreezesPerMonth = 2
+
+// Описание достижения
+type achievementDef struct {
+	Code  string
+	Title string
+	Hint  string
+	// Сколько дней истории нужно, чтобы заметить достижение после отметки; 0 — хватает счётчика приёмов
+	Days int
+	// Получено ли достижение при текущем прогрессе
+	Earned func(p achievementProgress) bool
+}
+
+// Прогресс пользователя, по которому выдаются достижения
+type achievementProgress struct {
+	Doses         int64 // всего отмеченных приёмов
+	CurrentStreak int   // дней подряд со всеми приёмами
+	LongestStreak int
+	PerfectWeek   bool // была неделя Пн–Вс со 100% приёмов
+	FreezesLeft   int  // заморозок осталось в этом месяце
+}
+
+// Запас дней к длине серии: дни без приёмов и заморозки серию не прерывают, но растягивают её
+const streakWindowSlack = 14
+
+var achievementDefs = []achievementDef{
+	{"first_dose", "🌱 Первый шаг", "отметить первый приём", 0, func(p achievementProgress) bool { return p.Doses >= 1 }},
+	{"perfect_week", "🏅 Идеальная неделя", "неделя Пн–Вс со 100% приёмов", 7, func(p achievementProgress) bool { return p.PerfectWeek }},
+	{"streak_7", "🔥 Неделя подряд", "7 дней подряд без пропусков", 7 + streakWindowSlack, func(p achievementProgress) bool { return p.LongestStreak >= 7 }},
+	{"streak_30", "💎 Месяц подряд", "30 дней подряд без пропусков", 30 + streakWindowSlack, func(p achievementProgress) bool { return p.LongestStreak >= 30 }},
+	{"streak_100", "👑 Сто дней", "100 дней подряд без пропусков", 100 + streakWindowSlack, func(p achievementProgress) bool { return p.LongestStreak >= 100 }},
+	{"doses_100", "💯 Сотня", "100 отмеченных приёмов", 0, func(p achievementProgress) bool { return p.Doses >= 100 }},
+	{"doses_500", "🏆 Пятьсот", "500 отмеченных приёмов", 0, func(p achievementProgress) bool { return p.Doses >= 500 }},
+}
+
+// Считает серии пользователя с from по сегодня с учётом уже потраченных заморозок.
+// День в серии — день, когда приняты все запланированные дозы; дни без приёмов серию не прерывают.
+func userProgress(user models.User, from time.Time) (achievementProgress, error) {
+	var p achievementProgress
+	// Приёмы по необходимости не планируются, поэтому в счётчик не входят
+	err := db.DB.Model(&models.IntakeLog{}).
+		Joins("JOIN supplements ON supplements.id = intake_logs.supplement_id").
+		Where("intake_logs.user_id = ? AND intake_logs.taken = ? AND supplements.schedule_type <> ?", user.ID, true, models.SchedulePRN).
+		Count(&p.Doses).Error
+	if err != nil {
+		return p, err
+	}
+	today := nowDate()
+	stats, err := buildPeriodStats(user, from, today)
+	if err != nil {
+		return p, err
+	}
+	monthStart := today.AddDate(0, 0, 1-today.Day())
+	frozen, used, err := loadStreakFreezes(user, minDate(from, monthStart))
+	if err != nil {
+		return p, err
+	}
+	walkStreak(&p, stats.Days, today, frozen, nil)
+	if user.StreakFreezeSince != nil && used[monthStart.Format(freezeMonthFormat)] < streakFreezesPerMonth {
+		p.FreezesLeft = streakFreezesPerMonth - used[monthStart.Format(freezeMonthFormat)]
+	}
+	return p, nil
+}
+
+// Ключ месяца в счётчике заморозок
+const freezeMonthFormat = "2006-01"
+
+// Загружает заморозки с from: дни, закрытые заморозкой, и число заморозок по месяцам пропуска
+func loadStreakFreezes(user models.User, from time.Time) (map[string]bool, map[string]int, error) {
+	var freezes []models.StreakFreeze
+	if err := db.DB.Where("user_id = ? AND date >= ?", user.ID, from).Find(&freezes).Error; err != nil {
+		return nil, nil, err
+	}
+	frozen := make(map[string]bool, len(freezes))
+	used := make(map[string]int)
+	for _, f := range freezes {
+		frozen[f.Date.UTC().Format(logDateFormat)] = true
+		used[f.Date.UTC().Format(freezeMonthFormat)]++
+	}
+	return frozen, used, nil
+}
+
+func minDate(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// Проходит дни статистики и заполняет серии и идеальную неделю в p. Пропуск, закрытый
+// заморозкой, серию не прерывает; spend решает, закрыть ли заморозкой новый пропуск (nil — нет)
+func walkStreak(p *achievementProgress, days []dayProgress, today time.Time, frozen map[string]bool, spend func(day time.Time, run int, lastFrozen bool) bool) {
+	run, lastFrozen := 0, false
+	weekFull, weekPlanned := true, false
+	for _, d := range days {
+		if schedule.Weekday(d.Date) == 0 {
+			weekFull, weekPlanned = true, false
+		}
+		full := d.Planned > 0 && d.Taken == d.Planned
+		pending := d.Date.Equal(today) && !full
+		if d.Planned > 0 && !pending {
+			weekPlanned = true
+			weekFull = weekFull && full
+		}
+		if schedule.Weekday(d.Date) == 6 && !pending && weekPlanned && weekFull {
+			p.PerfectWeek = true
+		}
+		if d.Planned == 0 || pending {
+			continue
+		}
+		switch {
+		case full:
+			run++
+			lastFrozen = false
+		case frozen[d.Date.UTC().Format(logDateFormat)]:
+			lastFrozen = true
+		case spend != nil && spend(d.Date, run, lastFrozen):
+			lastFrozen = true
+		default:
+			run = 0
+			lastFrozen = false
+		}
+		if run > p.LongestStreak {
+			p.LongestStreak = run
+		}
+	}
+	p.CurrentStreak = run
+}
+
+// Заморозки, которые можно потратить: лимит считается по месяцу пропущенного дня
+type freezeBudget struct {
+	user  models.User
+	used  map[string]int
+	spent []time.Time
+}
+
+// Тратит заморозку на пропуск day, если она включена до этого дня, серия уже идёт,
+// накануне заморозку не тратили и лимит на месяц пропуска не исчерпан
+func (b *freezeBudget) spend(day time.Time, run int, lastFrozen bool) bool {
+	if b.user.StreakFreezeSince == nil || day.Before(*b.user.StreakFreezeSince) {
+		return false
+	}
+	month := day.UTC().Format(freezeMonthFormat)
+	if run == 0 || lastFrozen || b.used[month] >= streakFreezesPerMonth {
+		return false
+	}
+	b.used[month]++
+	b.spent = append(b.spent, day)
+	return true
+}
+
+// Сколько дней назад ищем пропуски для заморозки: вчерашний день и запас на простой бота
+const streakFreezeLookbackDays = 31
+
+// Тратит заморозки на пропуски последних дней по today. Заморозки расходуются только здесь,
+// раз в сутки, когда прошедшие дни уже закрыты; просмотр прогресса их не тратит
+func spendStreakFreezes(log *zap.Logger, user models.User, today time.Time) error {
+	from := today.AddDate(0, 0, -streakFreezeLookbackDays)
+	stats, err := buildPeriodStats(user, from, today)
+	if err != nil {
+		return err
+	}
+	frozen, used, err := loadStreakFreezes(user, from.AddDate(0, 0, 1-from.Day()))
+	if err != nil {
+		return err
+	}
+	budget := freezeBudget{user: user, used: used}
+	walkStreak(&achievementProgress{}, stats.Days, today, frozen, budget.spend)
+	if len(budget.spent) == 0 {
+		return nil
+	}
+	freezes := make([]models.StreakFreeze, len(budget.spent))
+	for i, day := range budget.spent {
+		freezes[i] = models.StreakFreeze{UserID: user.ID, Date: day}
+		log.Info("Заморозка серии потрачена", zap.String("user_id", user.ID.String()), zap.String("date", day.Format(logDateFormat)))
+	}
+	return db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&freezes).Error
+}
+
+// Ежедневная проверка пропусков у пользователей с включённой заморозкой
+func SpendStreakFreezes(log *zap.Logger, now time.Time) {
+	var users []models.User
+	if err := db.DB.Where("streak_freeze_since IS NOT NULL").Find(&users).Error; err != nil {
+		log.Error("Ошибка получения пользователей", zap.Error(err))
+		return
+	}
+	today := schedule.Date(now)
+	for _, user := range users {
+		if err := spendStreakFreezes(log, user, today); err != nil {
+			log.Error("Не удалось потратить заморозку серии", zap.String("user_id", user.ID.String()), zap.Error(err))
+		}
+	}
+}
+
+// Начало истории для полного пересчёта серий — последний год
+func fullProgressFrom() time.Time {
+	return weekStart(nowDate().AddDate(0, 0, 1-adherenceHistoryDays))
+}
+
+// Выдаёт достижения, которые пользователь заработал по истории с from, и возвращает новые
+func evaluateAchievements(user models.User, from time.Time) ([]achievementDef, achievementProgress, error) {
+	p, err := userProgress(user, from)
+	if err != nil {
+		return nil, p, err
+	}
+	return awardAchievements(user, p, achievementDefs)
+}
+
+// Сохраняет достижения из defs, заработанные при прогрессе p, и возвращает новые
+func awardAchievements(user models.User, p achievementProgress, defs []achievementDef) ([]achievementDef, achievementProgress, error) {
+	var earned []achievementDef
+	for _, def := range defs {
+		if !def.Earned(p) {
+			continue
+		}
+		a := models.Achievement{UserID: user.ID, Code: def.Code}
+		res := db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&a)
+		if res.Error != nil {
+			return nil, p, res.Error
+		}
+		if res.RowsAffected > 0 {
+			earned = append(earned, def)
+		}
+	}
+	return earned, p, nil
+}
+
+// Проверяет достижения после отметки приёма и поздравляет с новыми.
+// Отметка — частая операция, поэтому история берётся только на глубину ещё не полученных достижений;
+// серии, растянутые дольше, засчитает полный пересчёт в /achievements.
+func announceAchievements(c tele.Context, log *zap.Logger, user models.User) {
+	var owned []string
+	if err := db.DB.Model(&models.Achievement{}).Where("user_id = ?", user.ID).Pluck("code", &owned).Error; err != nil {
+		log.Error("Ошибка проверки достижений", zap.Error(err))
+		return
+	}
+	has := make(map[string]bool, len(owned))
+	for _, code := range owned {
+		has[code] = true
+	}
+	var pending []achievementDef
+	days := 0
+	for _, def := range achievementDefs {
+		if has[def.Code] {
+			continue
+		}
+		pending = append(pending, def)
+		days = max(days, def.Days)
+	}
+	if len(pending) == 0 {
+		return
+	}
+	// Неделя Пн–Вс целиком: начало окна — понедельник
+	p, err := userProgress(user, weekStart(nowDate().AddDate(0, 0, -days)))
+	if err != nil {
+		log.Error("Ошибка проверки достижений", zap.Error(err))
+		return
+	}
+	earned, _, err := awardAchievements(user, p, pending)
+	if err != nil {
+		log.Error("Ошибка проверки достижений", zap.Error(err))
+		return
+	}
+	for _, def := range earned {
+		_ = c.Send(fmt.Sprintf("🎉 Новое достижение!\n\n%s — %s\n\nВсе достижения: /achievements", def.Title, def.Hint))
+	}
+}
+
+// Строит сообщение /achievements: полученные и оставшиеся достижения, серия и заморозка
+func buildAchievements(user models.User) (string, *tele.ReplyMarkup, error) {
+	_, p, err := evaluateAchievements(user, fullProgressFrom())
+	if err != nil {
+		return "", nil, err
+	}
+	var owned []models.Achievement
+	if err := db.DB.Where("user_id = ?", user.ID).Find(&owned).Error; err != nil {
+		return "", nil, err
+	}
+	awarded := make(map[string]time.Time, len(owned))
+	for _, a := range owned {
+		awarded[a.Code] = a.CreatedAt
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🏆 Достижения (%d/%d)\n\n", len(awarded), len(achievementDefs)))
+	for _, def := range achievementDefs {
+		if at, ok := awarded[def.Code]; ok {
+			sb.WriteString(fmt.Sprintf("✅ %s — %s\n", def.Title, utils.FormatDateRu(at)))
+		} else {
+			sb.WriteString(fmt.Sprintf("🔒 %s — %s\n", def.Title, def.Hint))
+		}
+	}
+	sb.WriteString(fmt.Sprintf("\n🔥 Текущая серия: %d дн. (рекорд %d)\n", p.CurrentStreak, p.LongestStreak))
+
+	markup := &tele.ReplyMarkup{}
+	if user.StreakFreezeSince != nil {
+		sb.WriteString(fmt.Sprintf("❄️ Заморозка серии включена: в этом месяце осталось %d из %d", p.FreezesLeft, streakFreezesPerMonth))
+		markup.Inline(markup.Row(markup.Data("❄️ Выключить заморозку", "ach_freeze")))
+	} else {
+		sb.WriteString(fmt.Sprintf("❄️ Заморозка серии выключена. Если включить, до %d случайных пропусков в месяц не прервут серию.", streakFreezesPerMonth))
+		markup.Inline(markup.Row(markup.Data("❄️ Включить заморозку", "ach_freeze")))
+	}
+	return sb.String(), markup, nil
+}
+
+// /achievements — полученные достижения и текущая серия
+func AchievementsHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		var user models.User
+		if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+			return c.Send("Пользователь не найден.")
+		}
+		msg, markup, err := buildAchievements(user)
+		if err != nil {
+			log.Error("Ошибка получения достижений", zap.Error(err))
+			return c.Send("Ошибка при получении достижений.")
+		}
+		return c.Send(msg, markup)
+	}
+}
+
+// Callback-хендлер включения и выключения заморозки серии
+func HandleStreakFreezeCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		var user models.User
+		if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Пользователь не найден"})
+		}
+		// Заморозка действует только на пропуски после включения
+		if user.StreakFreezeSince == nil {
+			today := nowDate()
+			user.StreakFreezeSince = &today
+		} else {
+			user.StreakFreezeSince = nil
+		}
+		if err := db.DB.Model(&user).Update("streak_freeze_since", user.StreakFreezeSince).Error; err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Ошибка сохранения"})
+		}
+		msg, markup, err := buildAchievements(user)
+		if err == nil {
+			_ = c.Edit(msg, markup)
+		}
+		return c.Respond()
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/models"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Дни подряд с from по шаблону: F — все дозы приняты, M — пропуск, P — не всё, "-" — приёмов нет
+func streakDays(from time.Time, pattern string) []dayProgress {
+	days := make([]dayProgress, len(pattern))
+	for i, c := range pattern {
+		d := dayProgress{Date: from.AddDate(0, 0, i)}
+		switch c {
+		case 'F':
+			d.Planned, d.Taken = 2, 2
+		case 'M':
+			d.Planned = 2
+		case 'P':
+			d.Planned, d.Taken = 2, 1
+		}
+		days[i] = d
+	}
+	return days
+}
+
+func TestWalkStreakFreezes(t *testing.T) {
+	oct27 := time.Date(2026, 10, 27, 0, 0, 0, 0, time.UTC)
+	before := oct27.AddDate(0, 0, -30)
+	tests := []struct {
+		name        string
+		pattern     string
+		since       *time.Time
+		used        map[string]int
+		frozen      []int // дни шаблона, уже закрытые заморозкой
+		today       int   // индекс сегодняшнего дня; -1 — сегодня за пределами шаблона
+		wantSpent   []int
+		wantCurrent int
+		wantLongest int
+	}{
+		{name: "заморозка выключена", pattern: "FFMF", today: -1, wantCurrent: 1, wantLongest: 2},
+		{name: "пропуск внутри серии", pattern: "FFMF", since: &before, today: -1, wantSpent: []int{2}, wantCurrent: 3, wantLongest: 3},
+		{name: "частичный приём — тоже пропуск", pattern: "FPF", since: &before, today: -1, wantSpent: []int{1}, wantCurrent: 2, wantLongest: 2},
+		{name: "два пропуска подряд прерывают серию", pattern: "FMMF", since: &before, today: -1, wantSpent: []int{1}, wantCurrent: 1, wantLongest: 1},
+		{name: "до начала серии не тратится", pattern: "MFF", since: &before, today: -1, wantCurrent: 2, wantLongest: 2},
+		{name: "дни без приёмов не в счёт", pattern: "F-M-F", since: &before, today: -1, wantSpent: []int{2}, wantCurrent: 2, wantLongest: 2},
+		{name: "пропуск до включения заморозки", pattern: "FMFMF", since: ptrDate(oct27.AddDate(0, 0, 2)), today: -1, wantSpent: []int{3}, wantCurrent: 2, wantLongest: 2},
+		// 28 и 30 октября — лимит октября, 1 и 3 ноября — лимит ноября
+		{name: "лимит по месяцу пропуска", pattern: "FMFMFMFMF", since: &before, today: -1, wantSpent: []int{1, 3, 5, 7}, wantCurrent: 5, wantLongest: 5},
+		{name: "лимит месяца уже потрачен", pattern: "FMFMFMF", since: &before, used: map[string]int{"2026-10": 1}, today: -1,
+			wantSpent: []int{1, 5}, wantCurrent: 2, wantLongest: 2},
+		{name: "уже закрытые дни не тратятся повторно", pattern: "FMFMF", since: &before, used: map[string]int{"2026-10": 1}, frozen: []int{1}, today: -1,
+			wantSpent: []int{3}, wantCurrent: 3, wantLongest: 3},
+		{name: "сегодня ещё не закончилось", pattern: "FFM", since: &before, today: 2, wantCurrent: 2, wantLongest: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			days := streakDays(oct27, tt.pattern)
+			today := oct27.AddDate(0, 0, len(days))
+			if tt.today >= 0 {
+				today = days[tt.today].Date
+			}
+			frozen := make(map[string]bool)
+			for _, i := range tt.frozen {
+				frozen[days[i].Date.Format(logDateFormat)] = true
+			}
+			used := tt.used
+			if used == nil {
+				used = make(map[string]int)
+			}
+			budget := freezeBudget{user: models.User{StreakFreezeSince: tt.since}, used: used}
+			var p achievementProgress
+			walkStreak(&p, days, today, frozen, budget.spend)
+
+			var want []time.Time
+			for _, i := range tt.wantSpent {
+				want = append(want, days[i].Date)
+			}
+			if !reflect.DeepEqual(budget.spent, want) {
+				t.Errorf("spent = %v, want %v", budget.spent, want)
+			}
+			if p.CurrentStreak != tt.wantCurrent || p.LongestStreak != tt.wantLongest {
+				t.Errorf("серия = %d (рекорд %d), want %d (%d)", p.CurrentStreak, p.LongestStreak, tt.wantCurrent, tt.wantLongest)
+			}
+		})
+	}
+}
+
+func ptrDate(t time.Time) *time.Time {
+	return &t
+}
+
+// Без spend просмотр прогресса ничего не тратит: новый пропуск прерывает серию
+func TestWalkStreakReadOnly(t *testing.T) {
+	oct27 := time.Date(2026, 10, 27, 0, 0, 0, 0, time.UTC)
+	days := streakDays(oct27, "FFMFF")
+	var p achievementProgress
+	walkStreak(&p, days, oct27.AddDate(0, 0, 10), map[string]bool{}, nil)
+	if p.CurrentStreak != 2 || p.LongestStreak != 2 {
+		t.Errorf("серия = %d (рекорд %d), want 2 (2)", p.CurrentStreak, p.LongestStreak)
+	}
+}
+
+func TestWalkStreakPerfectWeek(t *testing.T) {
+	monday := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{"FFFFFFF", true},
+		{"F-F-F--", true},
+		{"FFFFFFM", false},
+		{"-------", false},
+		{"FFFFFF", false},        // неделя не закончилась
+		{"MFFFFFFFFFFFFF", true}, // вторая неделя полная
+	}
+	for _, tt := range tests {
+		var p achievementProgress
+		walkStreak(&p, streakDays(monday, tt.pattern), monday.AddDate(0, 0, 30), nil, nil)
+		if p.PerfectWeek != tt.want {
+			t.Errorf("%s: PerfectWeek = %v, want %v", tt.pattern, p.PerfectWeek, tt.want)
+		}
+	}
+}
+
+// Приёмы по необходимости не входят в счётчик доз
+func TestUserProgressDosesExcludePRN(t *testing.T) {
+	fake := useFakeDB(t)
+	if _, err := userProgress(models.User{}, nowDate()); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.SQL) == 0 || !strings.Contains(fake.SQL[0], "supplements.schedule_type <>") {
+		t.Errorf("запрос счётчика доз не исключает приёмы по необходимости: %q", fake.SQL)
+	}
+}
@@ -1,12 +1,14 @@
 package handlers
 
 import (
+	"DailyDoseBot/internal/config"
 	"DailyDoseBot/internal/db"
 	"DailyDoseBot/internal/models"
+	"DailyDoseBot/internal/schedule"
 	"DailyDoseBot/internal/utils"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -24,6 +26,7 @@ var (
 	BtnAfternoon   tele.Btn
 	BtnEvening     tele.Btn
 	BtnAnytime     tele.Btn
+	BtnPRN         tele.Btn
 
 	AddFoodButtons *tele.ReplyMarkup
 	BtnFoodYes     tele.Btn
@@ -51,10 +54,12 @@ func initAddButtons() {
 	BtnAfternoon = AddTimeButtons.Data("🌤 День", "intake_time", "afternoon")
 	BtnEvening = AddTimeButtons.Data("🌙 Вечер", "intake_time", "evening")
 	BtnAnytime = AddTimeButtons.Data("🕓 Любое время", "intake_time", "any")
+	BtnPRN = AddTimeButtons.Data("💊 По необходимости", "intake_time", models.SchedulePRN)
 
 	AddTimeButtons.Inline(
 		AddTimeButtons.Row(BtnMorning, BtnAfternoon),
 		AddTimeButtons.Row(BtnEvening, BtnAnytime),
+		AddTimeButtons.Row(BtnPRN),
 	)
 
 	AddFoodButtons = &tele.ReplyMarkup{}
@@ -209,12 +214,20 @@ func AddTextHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
 
 		switch state.Step {
 		case 1:
-			state.Supplement.Name = c.Text()
+			name, err := parseSupplementName(c.Text())
+			if err != nil {
+				return c.Send("❌ Напиши название добавки.", utils.CancelKeyboard())
+			}
+			state.Supplement.Name = name
 			state.Step++
 			return c.Send("💊 Укажи дозировку добавки.\n\nНапример: 10 000 МЕ/день, 2 капсулы утром, 400 мг.\n\nПиши так, как тебе удобно — главное, чтобы ты сам понял! 😊", utils.CancelKeyboard())
 
 		case 2:
-			state.Supplement.Dosage = c.Text()
+			dosage, err := parseDosage(c.Text())
+			if err != nil {
+				return c.Send("❌ Напиши дозировку добавки.", utils.CancelKeyboard())
+			}
+			state.Supplement.Dosage = dosage
 			return c.Send("🕒 Когда обычно принимаешь эту добавку?\n\nВыбери подходящее время:", AddTimeButtons)
 		case 3:
 			return c.Send("😋 Принимаешь добавку вместе с едой?\n\nЭто важно для некоторых витаминов и минералов.\n\nВыбери вариант:", AddFoodButtons)
@@ -250,43 +263,26 @@ func AddTextHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
 Пример: 4 (4 недели), 1м (1 месяц), - (бессрочно)`
 			return c.Send(msg, utils.CancelKeyboard())
 		case 7:
-			input := strings.TrimSpace(c.Text())
-
-			if input == "-" {
-				state.Supplement.EndDate = nil
-				state.Step++
-				_ = c.Send("✅ Приём добавки будет бессрочным.")
-				return AddTextHandler(b, log)(c)
-			}
-
-			// Проверяем, это ли цифра или цифра+м/М
-			weeksRegex := regexp.MustCompile(`^\d+$`)
-			monthsRegex := regexp.MustCompile(`^(\d+)[мМ]$`)
-
-			var endDate time.Time
-
-			startDate := state.Supplement.StartDate
-			if weeksRegex.MatchString(input) {
-				weeks, err := strconv.Atoi(input)
-				if err != nil {
-					return c.Send("❌ Не удалось обработать число недель. Попробуй ещё раз, например: 3")
-				}
-				endDate = startDate.AddDate(0, 0, weeks*7)
-			} else if matches := monthsRegex.FindStringSubmatch(input); matches != nil {
-				months, err := strconv.Atoi(matches[1])
-				if err != nil {
-					return c.Send("❌ Не удалось обработать число месяцев. Попробуй ещё раз, например: 2м")
-				}
-				endDate = startDate.AddDate(0, months, 0)
-			} else {
+			// Число недель, число+м/М месяцев или "-"
+			endDate, err := parseCourseEnd(c.Text(), state.Supplement.StartDate)
+			if err != nil {
 				return c.Send("❌ Неверный формат.\n\nВведи количество недель (например, 3), месяцев (например, 2м) или '-' для бессрочного приёма.")
 			}
-
-			state.Supplement.EndDate = &endDate
+			state.Supplement.EndDate = endDate
 			state.Step++
-			_ = c.Send("✅ Приём добавки до " + endDate.Format("2006-01-02"))
+			if endDate == nil {
+				_ = c.Send("✅ Приём добавки будет бессрочным.")
+			} else {
+				_ = c.Send("✅ Приём добавки до " + endDate.Format("2006-01-02"))
+			}
 			return AddTextHandler(b, log)(c)
 		case 8:
+			if state.Supplement.IsPRN() {
+				// Для приёма по необходимости дни недели не нужны
+				state.Supplement.DaysOfWeek = datatypes.JSON([]byte("[0,1,2,3,4,5,6]"))
+				state.Step++
+				return AddTextHandler(b, log)(c)
+			}
 			if state.SelectedDays == nil {
 				state.SelectedDays = make(map[int]bool)
 			}
@@ -295,6 +291,9 @@ func AddTextHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
 
 		case 9:
 			state.Step++
+			if state.Supplement.IsPRN() {
+				return c.Send("🔢 Сколько максимум приёмов в день допустимо?\n\nНапиши число, например: 2.\nИли \"-\", если ограничения нет.", utils.CancelKeyboard())
+			}
 
 			msg := `⏰ В какое время напоминать о приёме?
 
@@ -305,31 +304,32 @@ func AddTextHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
 		case 10:
 			input := strings.TrimSpace(c.Text())
 
-			if strings.ToLower(input) == "нет" {
+			if state.Supplement.IsPRN() {
+				maxDoses, err := parseMaxDoses(input)
+				if err != nil {
+					return c.Send("❌ Напиши положительное число, например: 2. Или \"-\", если ограничения нет.")
+				}
+				state.Supplement.MaxDailyDoses = maxDoses
 				state.Supplement.ReminderEnabled = false
 				state.Supplement.ReminderTimes = datatypes.JSON([]byte("[]"))
 				state.Step++
-				_ = c.Delete() // удалить сообщение пользователя
-				_ = c.Send("🔕 Напоминания отключены.", &tele.ReplyMarkup{})
 				return AddTextHandler(b, log)(c)
 			}
 
-			times := strings.Split(input, ",")
-			var cleanedTimes []string
-			timeRegex := regexp.MustCompile(`^(?:[01]\d|2[0-3]):[0-5]\d$`)
-
-			for _, t := range times {
-				t = strings.TrimSpace(t)
-				if !timeRegex.MatchString(t) {
-					return c.Send("❌ Неверный формат времени.\n\nИспользуй формат ЧЧ:ММ, например: 08:00, 13:30.\n\nИли напиши 'нет', если не нужны напоминания.")
-				}
-				// Проверяем кратность 30 минутам
-				parts := strings.Split(t, ":")
-				minutes, err := strconv.Atoi(parts[1])
-				if err != nil || (minutes != 0 && minutes != 30) {
-					return c.Send("❌ Время должно быть кратно 30 минутам (допустимы только минуты '00' или '30'). Например: 08:00, 13:30.\n\nИли напиши 'нет', если не нужны напоминания.")
-				}
-				cleanedTimes = append(cleanedTimes, t)
+			cleanedTimes, err := parseReminderTimes(input)
+			switch {
+			case errors.Is(err, errReminderStep):
+				return c.Send("❌ Время должно быть кратно 30 минутам (допустимы только минуты '00' или '30'). Например: 08:00, 13:30.\n\nИли напиши 'нет', если не нужны напоминания.")
+			case err != nil:
+				return c.Send("❌ Неверный формат времени.\n\nИспользуй формат ЧЧ:ММ, например: 08:00, 13:30.\n\nИли напиши 'нет', если не нужны напоминания.")
+			}
+			if len(cleanedTimes) == 0 {
+				state.Supplement.ReminderEnabled = false
+				state.Supplement.ReminderTimes = datatypes.JSON([]byte("[]"))
+				state.Step++
+				_ = c.Delete() // удалить сообщение пользователя
+				_ = c.Send("🔕 Напоминания отключены.", &tele.ReplyMarkup{})
+				return AddTextHandler(b, log)(c)
 			}
 
 			jsonTimes, err := json.Marshal(cleanedTimes)
@@ -372,6 +372,9 @@ func AddTextHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
 			} else {
 				reminderTimes = "Отключены"
 			}
+			if state.Supplement.IsPRN() {
+				reminderTimes = "Нет"
+			}
 			withFood := "—"
 			if state.Supplement.WithFood {
 				withFood = "Да"
@@ -393,6 +396,9 @@ func AddTextHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
 			case "any":
 				intakeTime = "Любое время"
 			}
+			if state.Supplement.IsPRN() {
+				intakeTime = prnScheduleText(state.Supplement)
+			}
 			msg := "🩺 Вот что я записал:\n" +
 				"• Название: " + state.Supplement.Name + "\n" +
 				"• Дозировка: " + state.Supplement.Dosage + "\n" +
@@ -459,8 +465,15 @@ func HandleTimeCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error
 			valueSend = "Вечер"
 		case "any":
 			valueSend = "Любое время"
+		case models.SchedulePRN:
+			valueSend = "По необходимости"
 		}
 		state.Supplement.IntakeTime = value
+		if value == models.SchedulePRN {
+			// Приём по необходимости: без расписания и напоминаний, время — любое
+			state.Supplement.IntakeTime = "any"
+			state.Supplement.ScheduleType = models.SchedulePRN
+		}
 		state.Step++
 		log.Info("Step", zap.Int("step", state.Step))
 		addStates.Unlock()
@@ -557,10 +570,15 @@ func parseDate(s string) (time.Time, error) {
 	return parsed, err
 }
 
+// Сегодняшняя дата по календарю сервера
 func nowDate() time.Time {
-	return time.Now().Truncate(24 * time.Hour)
+	return schedule.Date(time.Now())
 }
 
-func InitHandlers() {
+// Настройки приложения, нужные хендлерам
+var appConfig *config.Config
+
+func InitHandlers(cfg *config.Config) {
+	appConfig = cfg
 	initAddButtons()
 }
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/db"
+	"DailyDoseBot/internal/models"
+	"DailyDoseBot/internal/schedule"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// Окна, за которые считается процент соблюдения
+var adherenceWindows = []int{7, 30, 90}
+
+// Глубина истории для серий: самая длинная серия ищется за последний год
+const adherenceHistoryDays = 365
+
+// Соблюдение плана по одной добавке
+type adherence struct {
+	Planned       []int     // запланировано доз по окнам adherenceWindows
+	Done          []float64 // принято доз по окнам с учётом частичных
+	CurrentStreak int       // дней подряд с полностью принятыми дозами
+	LongestStreak int
+	MissedSlot    string // время, в которое чаще всего пропускают (за 90 дней)
+	MissedSlotN   int
+	MissedWeekday int // день недели с наибольшим числом пропусков, -1 — пропусков нет
+	MissedDayN    int
+}
+
+// Считает соблюдение по записям добавки; сегодняшние неотмеченные дозы ещё не пропущены
+func computeAdherence(s models.Supplement, entries []models.IntakeLog, today time.Time) adherence {
+	a := adherence{
+		Planned:       make([]int, len(adherenceWindows)),
+		Done:          make([]float64, len(adherenceWindows)),
+		MissedWeekday: -1,
+	}
+	logs := make(map[string]models.IntakeLog, len(entries))
+	for _, e := range entries {
+		logs[doseKey(e.SupplementID, e.IntakeDate, e.IntakeTime)] = e
+	}
+	missWindow := adherenceWindows[len(adherenceWindows)-1]
+	slotMisses := make(map[string]int)
+	var dayMisses [7]int
+
+	run := 0
+	for day := today.AddDate(0, 0, 1-adherenceHistoryDays); !day.After(today); day = day.AddDate(0, 0, 1) {
+		if !schedule.DueOn(s, day) {
+			continue
+		}
+		age := int(today.Sub(day).Hours() / 24)
+		full, counted := true, false
+		for _, t := range schedule.Times(s) {
+			entry, ok := logs[doseKey(s.ID, day, t)]
+			if !ok && day.Equal(today) {
+				continue
+			}
+			counted = true
+			for i, w := range adherenceWindows {
+				if age < w {
+					a.Planned[i]++
+					a.Done[i] += entry.Portion()
+				}
+			}
+			if !entry.Taken || entry.IsPartial() {
+				full = false
+			}
+			if !entry.Taken && age < missWindow {
+				slotMisses[t]++
+				dayMisses[schedule.Weekday(day)]++
+			}
+		}
+		if !counted {
+			continue
+		}
+		if full {
+			run++
+			if run > a.LongestStreak {
+				a.LongestStreak = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	a.CurrentStreak = run
+
+	for _, t := range schedule.Times(s) {
+		if t != "" && slotMisses[t] > a.MissedSlotN {
+			a.MissedSlot, a.MissedSlotN = t, slotMisses[t]
+		}
+	}
+	for d, n := range dayMisses {
+		if n > a.MissedDayN {
+			a.MissedWeekday, a.MissedDayN = d, n
+		}
+	}
+	return a
+}
+
+// Загружает записи о приёмах за историю соблюдения одним запросом и раскладывает по добавкам
+func loadAdherenceLogs(user models.User, today time.Time) (map[uuid.UUID][]models.IntakeLog, error) {
+	var entries []models.IntakeLog
+	if err := db.DB.Where("user_id = ? AND intake_date BETWEEN ? AND ?", user.ID, today.AddDate(0, 0, 1-adherenceHistoryDays), today).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	bySupplement := make(map[uuid.UUID][]models.IntakeLog)
+	for _, e := range entries {
+		bySupplement[e.SupplementID] = append(bySupplement[e.SupplementID], e)
+	}
+	return bySupplement, nil
+}
+
+// Соблюдение плана по одной добавке за последний год
+func supplementAdherence(s models.Supplement) (adherence, error) {
+	today := nowDate()
+	var entries []models.IntakeLog
+	if err := db.DB.Where("supplement_id = ? AND intake_date BETWEEN ? AND ?", s.ID, today.AddDate(0, 0, 1-adherenceHistoryDays), today).Find(&entries).Error; err != nil {
+		return adherence{}, err
+	}
+	return computeAdherence(s, entries, today), nil
+}
+
+// Склонение слова "раз" после числа
+func timesWord(n int) string {
+	if n%10 >= 2 && n%10 <= 4 && (n%100 < 10 || n%100 >= 20) {
+		return "раза"
+	}
+	return "раз"
+}
+
+// Текст блока соблюдения для карточки добавки и статистики
+func adherenceText(a adherence) string {
+	var parts []string
+	for i, w := range adherenceWindows {
+		if a.Planned[i] == 0 {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%d дн. — %d%%", w, percentOf(a.Done[i], a.Planned[i])))
+	}
+	if len(parts) == 0 {
+		return "📊 Соблюдение: пока нет данных"
+	}
+	var sb strings.Builder
+	sb.WriteString("📊 Соблюдение: " + strings.Join(parts, " · "))
+	sb.WriteString(fmt.Sprintf("\n🔥 Серия: %d дн. (рекорд %d)", a.CurrentStreak, a.LongestStreak))
+	if a.MissedSlotN > 0 {
+		sb.WriteString(fmt.Sprintf("\n⏰ Чаще пропускается: %s (%d %s)", a.MissedSlot, a.MissedSlotN, timesWord(a.MissedSlotN)))
+	}
+	if a.MissedWeekday >= 0 {
+		weekdaysRu := []string{"Пн", "Вт", "Ср", "Чт", "Пт", "Сб", "Вс"}
+		sb.WriteString(fmt.Sprintf("\n📅 Сложнее всего: %s (%d %s)", weekdaysRu[a.MissedWeekday], a.MissedDayN, timesWord(a.MissedDayN)))
+	}
+	return sb.String()
+}
+
+// Callback-хендлер кнопки "По добавкам" в /stats: соблюдение и серии по каждой добавке
+func HandleStatsSupplementsCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		var user models.User
+		if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Пользователь не найден"})
+		}
+		var supplements []models.Supplement
+		if err := db.DB.Where("user_id = ?", user.ID).Order("name").Find(&supplements).Error; err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Ошибка при получении добавок"})
+		}
+		today := nowDate()
+		logs, err := loadAdherenceLogs(user, today)
+		if err != nil {
+			log.Error("Ошибка получения приёмов", zap.Error(err))
+			return c.Respond(&tele.CallbackResponse{Text: "Ошибка при получении статистики"})
+		}
+		var sb strings.Builder
+		sb.WriteString("💊 Статистика по добавкам\n")
+		shown := 0
+		for _, s := range supplements {
+			// У приёма по необходимости нет плана
+			if s.IsPRN() {
+				continue
+			}
+			shown++
+			sb.WriteString("\n" + s.Name + "\n")
+			sb.WriteString(adherenceText(computeAdherence(s, logs[s.ID], today)) + "\n")
+		}
+		if shown == 0 {
+			sb.WriteString("\nНет добавок с расписанием.")
+		}
+		markup := &tele.ReplyMarkup{}
+		from, to := statsPeriod(statsThisWeek, today)
+		markup.Inline(markup.Row(statsButton(markup, "⬅️ К статистике", statsThisWeek, from, to)))
+		_ = c.Edit(sb.String(), markup)
+		return c.Respond()
+	}
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/db"
+	"DailyDoseBot/internal/models"
+	"DailyDoseBot/internal/schedule"
+	"DailyDoseBot/internal/utils"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+	"gorm.io/gorm"
+)
+
+// Сколько ждать ответа базы при проверке соединения
+const dbPingTimeout = 3 * time.Second
+
+const adminHelp = `🛠 Команды администратора:
+/admin stats — пользователи, активность и напоминания за сутки
+/admin db — состояние соединения с базой
+/admin user <telegram_id> — расписание и статистика пользователя
+/admin debug <telegram_id> — подробный отчёт за прошлую неделю
+/admin dryrun <telegram_id> <ЧЧ:ММ> [ДД.ММ.ГГГГ] — какие напоминания ушли бы пользователю в это время
+/broadcast — рассылка пользователям`
+
+// Является ли пользователь администратором бота
+func isAdmin(telegramID int64) bool {
+	for _, id := range appConfig.AdminIDs {
+		if id == telegramID {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware для админ-команд: остальным пользователям команды не видны
+func AdminOnly(log *zap.Logger) tele.MiddlewareFunc {
+	return func(next tele.HandlerFunc) tele.HandlerFunc {
+		return func(c tele.Context) error {
+			if c.Sender() == nil || !isAdmin(c.Sender().ID) {
+				if c.Sender() != nil {
+					log.Warn("security: попытка вызвать админ-команду", zap.Int64("telegram_id", c.Sender().ID), zap.String("text", c.Text()))
+				}
+				return nil
+			}
+			return next(c)
+		}
+	}
+}
+
+// /admin <команда> — диагностика для администраторов
+func AdminHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		args := c.Args()
+		if len(args) == 0 {
+			return c.Send(adminHelp)
+		}
+		var (
+			msg string
+			err error
+		)
+		switch args[0] {
+		case "stats":
+			msg, err = adminStats()
+		case "db":
+			msg = adminDBStatus()
+		case "user":
+			msg, err = adminWithUser(args, adminUserInfo)
+		case "debug":
+			msg, err = adminWithUser(args, buildDebugStats)
+		case "dryrun":
+			msg, err = adminDryRun(args)
+		default:
+			msg = adminHelp
+		}
+		if err != nil {
+			log.Error("Ошибка админ-команды", zap.String("command", args[0]), zap.Error(err))
+			return c.Send("Ошибка: " + err.Error())
+		}
+		return c.Send(msg)
+	}
+}
+
+// Находит пользователя по Telegram ID из аргументов и строит по нему ответ
+func adminWithUser(args []string, build func(models.User) (string, error)) (string, error) {
+	if len(args) < 2 {
+		return "Укажи Telegram ID пользователя.", nil
+	}
+	user, err := adminFindUser(args[1])
+	if err != nil {
+		return "", err
+	}
+	return build(user)
+}
+
+func adminFindUser(arg string) (models.User, error) {
+	var user models.User
+	telegramID, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return user, fmt.Errorf("некорректный Telegram ID %q", arg)
+	}
+	if err := db.DB.First(&user, "telegram_id = ?", telegramID).Error; err != nil {
+		return user, fmt.Errorf("пользователь %d не найден", telegramID)
+	}
+	return user, nil
+}
+
+// Пользователи, активность по журналу отметок и напоминания за сутки
+func adminStats() (string, error) {
+	now := time.Now()
+	var users, newUsers, supplements, reminders, active24h, active7d int64
+	// Активность — по журналу изменений отметок: туда попадает каждое действие с приёмом
+	queries := []*gorm.DB{
+		db.DB.Model(&models.User{}).Count(&users),
+		db.DB.Model(&models.User{}).Where("created_at >= ?", now.AddDate(0, 0, -7)).Count(&newUsers),
+		db.DB.Model(&models.Supplement{}).Count(&supplements),
+		db.DB.Model(&models.Supplement{}).Where("reminder_enabled = ?", true).Count(&reminders),
+		db.DB.Model(&models.IntakeLogAudit{}).Where("created_at >= ?", now.Add(-24*time.Hour)).Distinct("user_id").Count(&active24h),
+		db.DB.Model(&models.IntakeLogAudit{}).Where("created_at >= ?", now.AddDate(0, 0, -7)).Distinct("user_id").Count(&active7d),
+	}
+	for _, q := range queries {
+		if q.Error != nil {
+			return "", q.Error
+		}
+	}
+	sent, failed, since := reminderStats()
+	return fmt.Sprintf("📊 Бот\n\nПользователей: %d (новых за 7 дней: %d)\nАктивных за 24 ч: %d, за 7 дней: %d\nДобавок: %d, с напоминаниями: %d\n\n⏰ Напоминания с %s:\nОтправлено: %d\nНе доставлено: %d",
+		users, newUsers, active24h, active7d, supplements, reminders, since.Format("02.01 15:04"), sent, failed), nil
+}
+
+// Проверка соединения с базой и состояние пула
+func adminDBStatus() string {
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		return "🔴 База недоступна: " + err.Error()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dbPingTimeout)
+	defer cancel()
+	started := time.Now()
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return "🔴 База не отвечает: " + err.Error()
+	}
+	stats := sqlDB.Stats()
+	return fmt.Sprintf("🟢 База доступна, ответ за %s\n\nСоединений: открыто %d, занято %d, свободно %d\nОжиданий соединения: %d",
+		time.Since(started).Round(time.Millisecond), stats.OpenConnections, stats.InUse, stats.Idle, stats.WaitCount)
+}
+
+// Добавки пользователя, план на сегодня и статистика за неделю
+func adminUserInfo(user models.User) (string, error) {
+	var supplements []models.Supplement
+	if err := db.DB.Where("user_id = ?", user.ID).Order("name").Find(&supplements).Error; err != nil {
+		return "", err
+	}
+	today := nowDate()
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("👤 %s (%d), с %s\nЧасовой пояс: %s\n", user.Name, user.TelegramID, utils.FormatDateRu(user.CreatedAt), timezoneLabel(user)))
+	if user.NextReportAt != nil {
+		sb.WriteString("Следующий недельный отчёт: " + user.NextReportAt.In(userLocation(user)).Format("02.01.2006 15:04") + "\n")
+	}
+	sb.WriteString(fmt.Sprintf("\n💊 Добавок: %d\n", len(supplements)))
+	for _, s := range supplements {
+		sb.WriteString("\n" + supplementInfoText(s) + "\n")
+	}
+
+	sb.WriteString("\n📅 План на сегодня:\n")
+	occurrences := schedule.ExpandAll(supplements, today, today)
+	if len(occurrences) == 0 {
+		sb.WriteString("приёмов нет\n")
+	}
+	names := make(map[uuid.UUID]string, len(supplements))
+	for _, s := range supplements {
+		names[s.ID] = s.Name
+	}
+	for _, occ := range occurrences {
+		sb.WriteString("• " + doseLabel(names[occ.SupplementID], occ.Time) + "\n")
+	}
+
+	stats, err := buildPeriodStats(user, today.AddDate(0, 0, -6), today)
+	if err != nil {
+		return "", err
+	}
+	planned, done := 0, 0.0
+	var bar string
+	for _, d := range stats.Days {
+		planned += d.Planned
+		done += d.Done
+		bar += dayProgressIcon(d)
+	}
+	sb.WriteString(fmt.Sprintf("\n📈 Последние 7 дней: %s %d%%", bar, percentOf(done, planned)))
+	return sb.String(), nil
+}
+
+// Пробный прогон SendReminders для одного пользователя в заданный момент, без отправки
+func adminDryRun(args []string) (string, error) {
+	if len(args) < 3 {
+		return "Формат: /admin dryrun <telegram_id> <ЧЧ:ММ> [ДД.ММ.ГГГГ]", nil
+	}
+	user, err := adminFindUser(args[1])
+	if err != nil {
+		return "", err
+	}
+	if !clockRegex.MatchString(args[2]) {
+		return "", fmt.Errorf("некорректное время %q", args[2])
+	}
+	day := nowDate()
+	if len(args) > 3 {
+		if day, err = time.Parse("02.01.2006", args[3]); err != nil {
+			return "", fmt.Errorf("некорректная дата %q", args[3])
+		}
+	}
+	// Напоминания считаются по времени сервера, как и в cron
+	at, err := atClock(day, args[2])
+	if err != nil {
+		return "", err
+	}
+	var supplements []models.Supplement
+	if err := db.DB.Where("user_id = ? AND reminder_enabled = ?", user.ID, true).Find(&supplements).Error; err != nil {
+		return "", err
+	}
+	due := dueReminders(supplements, at)
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🧪 Пробный прогон напоминаний для %d на %s\n\n", user.TelegramID, at.Format("02.01.2006 15:04")))
+	if len(due) == 0 {
+		sb.WriteString("Напоминаний не было бы.")
+	}
+	for _, r := range due {
+		msg, _ := reminderMessage(r.Supplement, r.Time, day)
+		sb.WriteString("• " + msg + "\n\n")
+	}
+	return sb.String(), nil
+}
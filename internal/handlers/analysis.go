@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/db"
+	"DailyDoseBot/internal/models"
+	"DailyDoseBot/internal/schedule"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// Период анализа пропусков: восемь полных недель до вчерашнего дня
+const analysisDays = 56
+
+// Напоминания с этого времени считаются поздними
+const lateReminderClock = "21:00"
+
+// Разница в процентных пунктах, которую стоит показать как закономерность
+const analysisGap = 15
+
+// Минимум запланированных доз в срезе, чтобы делать по нему выводы
+const analysisMinDoses = 3
+
+// Сколько советов показывать
+const maxSuggestions = 3
+
+// "по средам" — для советов
+var weekdaysDativeRu = []string{"по понедельникам", "по вторникам", "по средам", "по четвергам", "по пятницам", "по субботам", "по воскресеньям"}
+
+// Пропуски одной добавки в одно время в один день недели
+type missCell struct {
+	Supplement string
+	Time       string
+	Weekday    int
+	weekTotals
+}
+
+func (m missCell) missRate() float64 {
+	return 1 - m.Done/float64(m.Planned)
+}
+
+// Строит текст /analysis: матрица время × день недели, закономерности и советы
+func buildAnalysis(user models.User) (string, error) {
+	to := nowDate().AddDate(0, 0, -1)
+	from := to.AddDate(0, 0, 1-analysisDays)
+	var supplements []models.Supplement
+	if err := db.DB.Where("user_id = ?", user.ID).Order("name").Find(&supplements).Error; err != nil {
+		return "", err
+	}
+	logs, err := loadIntakeLogs(user, from, to)
+	if err != nil {
+		return "", err
+	}
+	names := make(map[uuid.UUID]string, len(supplements))
+	for _, s := range supplements {
+		names[s.ID] = s.Name
+	}
+
+	matrix := make(map[string]*[7]weekTotals)
+	cells := make(map[string]*missCell)
+	var late, early, weekend, weekday weekTotals
+	for _, occ := range schedule.ExpandAll(supplements, from, to) {
+		entry := logs[doseKey(occ.SupplementID, occ.Date, occ.Time)]
+		portion := entry.Portion()
+		wd := schedule.Weekday(occ.Date)
+		if matrix[occ.Time] == nil {
+			matrix[occ.Time] = &[7]weekTotals{}
+		}
+		matrix[occ.Time][wd].Planned++
+		matrix[occ.Time][wd].Done += portion
+
+		key := fmt.Sprintf("%s|%s|%d", occ.SupplementID, occ.Time, wd)
+		if cells[key] == nil {
+			cells[key] = &missCell{Supplement: names[occ.SupplementID], Time: occ.Time, Weekday: wd}
+		}
+		cells[key].Planned++
+		cells[key].Done += portion
+
+		if occ.Time != "" {
+			bucket := &early
+			// "ЧЧ:ММ" сравниваются как строки
+			if occ.Time >= lateReminderClock {
+				bucket = &late
+			}
+			bucket.Planned++
+			bucket.Done += portion
+		}
+		bucket := &weekday
+		if wd >= 5 {
+			bucket = &weekend
+		}
+		bucket.Planned++
+		bucket.Done += portion
+	}
+	if len(matrix) == 0 {
+		return "🔎 Анализ пропусков\n\nЗа последние 8 недель не было запланированных приёмов.", nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🔎 <b>Анализ пропусков с %s по %s</b>\n\n", from.Format("02.01"), to.Format("02.01")))
+	sb.WriteString("Выполнение плана в % по времени приёма и дням недели:\n<pre>")
+	sb.WriteString("       Пн  Вт  Ср  Чт  Пт  Сб  Вс\n")
+	times := make([]string, 0, len(matrix))
+	for t := range matrix {
+		times = append(times, t)
+	}
+	sort.Strings(times)
+	for _, t := range times {
+		label := t
+		if label == "" {
+			label = "—"
+		}
+		sb.WriteString(fmt.Sprintf("%-5s", label))
+		for _, cell := range matrix[t] {
+			if cell.Planned == 0 {
+				sb.WriteString("   ·")
+			} else {
+				sb.WriteString(fmt.Sprintf("%4d", cell.percent()))
+			}
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("</pre>\n")
+
+	// Закономерности: поздние напоминания и выходные
+	var patterns []string
+	if late.Planned >= analysisMinDoses && early.Planned >= analysisMinDoses && early.percent()-late.percent() >= analysisGap {
+		patterns = append(patterns, fmt.Sprintf("🌙 Поздние напоминания (с %s) выполняются на %d%%, остальные — на %d%%", lateReminderClock, late.percent(), early.percent()))
+	}
+	if weekend.Planned >= analysisMinDoses && weekday.Planned >= analysisMinDoses && weekday.percent()-weekend.percent() >= analysisGap {
+		patterns = append(patterns, fmt.Sprintf("🏖 В выходные выполнение %d%%, в будни — %d%%", weekend.percent(), weekday.percent()))
+	}
+	if len(patterns) > 0 {
+		sb.WriteString("\nЗакономерности:\n" + strings.Join(patterns, "\n") + "\n")
+	}
+
+	suggestions := analysisSuggestions(cells)
+	if len(suggestions) > 0 {
+		sb.WriteString("\n💡 Советы:\n")
+		for _, s := range suggestions {
+			sb.WriteString("• " + html.EscapeString(s) + "\n")
+		}
+	} else if len(patterns) == 0 {
+		sb.WriteString("\nЯвных закономерностей в пропусках нет — так держать! 💪")
+	}
+	return sb.String(), nil
+}
+
+// Советы по срезам добавка × время × день недели, где пропускают хотя бы половину доз
+func analysisSuggestions(cells map[string]*missCell) []string {
+	var worst []*missCell
+	for _, c := range cells {
+		if c.Planned >= analysisMinDoses && c.missRate() >= 0.5 {
+			worst = append(worst, c)
+		}
+	}
+	sort.Slice(worst, func(i, j int) bool {
+		if worst[i].missRate() != worst[j].missRate() {
+			return worst[i].missRate() > worst[j].missRate()
+		}
+		if worst[i].Supplement != worst[j].Supplement {
+			return worst[i].Supplement < worst[j].Supplement
+		}
+		if worst[i].Time != worst[j].Time {
+			return worst[i].Time < worst[j].Time
+		}
+		return worst[i].Weekday < worst[j].Weekday
+	})
+
+	var suggestions []string
+	for _, c := range worst {
+		if len(suggestions) == maxSuggestions {
+			break
+		}
+		missed := c.Planned - int(c.Done+0.5)
+		day := weekdaysDativeRu[c.Weekday]
+		switch {
+		case c.Time != "" && c.Time >= lateReminderClock:
+			earlier, _ := time.Parse("15:04", c.Time)
+			suggestions = append(suggestions, fmt.Sprintf("Перенеси %s с %s на %s — %s ты пропускаешь этот приём (%d из %d)",
+				c.Supplement, c.Time, earlier.Add(-time.Hour).Format("15:04"), day, missed, c.Planned))
+		case c.Weekday >= 5:
+			suggestions = append(suggestions, fmt.Sprintf("%s на выходных часто пропускается: %s %d из %d. Попробуй привязать приём к завтраку или поставить напоминание позже",
+				doseLabel(c.Supplement, c.Time), day, missed, c.Planned))
+		case c.Time != "":
+			suggestions = append(suggestions, fmt.Sprintf("%s в %s %s пропускается %d из %d раз — попробуй другое время в этот день",
+				c.Supplement, c.Time, day, missed, c.Planned))
+		default:
+			suggestions = append(suggestions, fmt.Sprintf("%s %s пропускается %d из %d раз — включи напоминание, чтобы не забывать",
+				c.Supplement, day, missed, c.Planned))
+		}
+	}
+	return suggestions
+}
+
+// /analysis — когда и почему пропускаются приёмы
+func AnalysisHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		var user models.User
+		if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+			return c.Send("Пользователь не найден.")
+		}
+		msg, err := buildAnalysis(user)
+		if err != nil {
+			log.Error("Ошибка анализа пропусков", zap.Error(err))
+			return c.Send("Ошибка при анализе пропусков.")
+		}
+		return c.Send(msg, &tele.SendOptions{ParseMode: tele.ModeHTML})
+	}
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/db"
+	"DailyDoseBot/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// Действия и источники изменений в журнале отметок
+const (
+	auditActionTaken   = "taken"
+	auditActionSkipped = "skipped"
+	auditActionMissed  = "missed"
+	auditActionPartial = "partial"
+	auditActionUndo    = "undo"
+
+	auditSourceReminder = "reminder"
+	auditSourceLog      = "log"
+)
+
+// Записывает изменение отметки о приёме в журнал
+func auditIntake(log *zap.Logger, entry models.IntakeLog, action, source string) {
+	record := models.IntakeLogAudit{
+		UserID:       entry.UserID,
+		SupplementID: entry.SupplementID,
+		IntakeDate:   entry.IntakeDate,
+		IntakeTime:   entry.IntakeTime,
+		Action:       action,
+		Source:       source,
+	}
+	if err := db.DB.Create(&record).Error; err != nil {
+		log.Error("Не удалось записать изменение отметки в журнал", zap.Error(err))
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/db"
+	"DailyDoseBot/internal/models"
+	"errors"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Ошибка доступа к чужим данным
+var errForbidden = errors.New("доступ запрещён")
+
+// Загружает добавку и проверяет, что она принадлежит пользователю.
+// Попытка обратиться к чужой добавке логируется как событие безопасности.
+func ownedSupplement(log *zap.Logger, user models.User, id uuid.UUID) (models.Supplement, error) {
+	var supplement models.Supplement
+	if err := db.DB.First(&supplement, "id = ?", id).Error; err != nil {
+		return supplement, err
+	}
+	if supplement.UserID != user.ID {
+		log.Warn("security: доступ к чужой добавке",
+			zap.Int64("telegram_id", user.TelegramID),
+			zap.String("user_id", user.ID.String()),
+			zap.String("supplement_id", id.String()),
+			zap.String("owner_id", supplement.UserID.String()))
+		return models.Supplement{}, errForbidden
+	}
+	return supplement, nil
+}
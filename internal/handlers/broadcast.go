@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/callback"
+	"DailyDoseBot/internal/db"
+	"DailyDoseBot/internal/models"
+	"DailyDoseBot/internal/utils"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// Аудитории рассылки
+const (
+	broadcastAll       = "all"       // все пользователи
+	broadcastActive    = "active"    // отмечали приёмы за последние N дней
+	broadcastReminders = "reminders" // есть хотя бы одно включённое напоминание
+)
+
+// Действия кнопок рассылки
+const (
+	broadcastTarget  = "t" // выбрать аудиторию
+	broadcastConfirm = "y" // отправить
+	broadcastCancel  = "n" // отменить
+)
+
+// Пауза между сообщениями: Telegram разрешает около 30 сообщений в секунду
+const broadcastInterval = 40 * time.Millisecond
+
+// Сколько рассылок может ждать в очереди
+const broadcastQueueSize = 10
+
+// Максимальный период активности для аудитории "активные"
+const maxBroadcastDays = 365
+
+const broadcastUsage = `📣 Рассылка
+/broadcast — выбрать аудиторию кнопками
+/broadcast all — всем пользователям
+/broadcast active <N> — отмечавшим приёмы за последние N дней
+/broadcast reminders — пользователям с включёнными напоминаниями`
+
+// Черновик рассылки администратора до подтверждения
+type broadcastDraft struct {
+	Seq    int
+	Target string
+	Days   int
+	Text   string
+}
+
+// Рассылка, поставленная в очередь
+type broadcastJob struct {
+	AdminID    int64
+	Text       string
+	Recipients []int64
+	Label      string
+}
+
+var broadcastDrafts = struct {
+	sync.Mutex
+	m   map[int64]*broadcastDraft
+	seq int
+}{m: make(map[int64]*broadcastDraft)}
+
+var (
+	broadcastQueue = make(chan broadcastJob, broadcastQueueSize)
+	broadcastOnce  sync.Once
+)
+
+// Подпись аудитории для предпросмотра и отчёта
+func broadcastTargetLabel(target string, days int) string {
+	switch target {
+	case broadcastActive:
+		return fmt.Sprintf("активные за %d дн.", days)
+	case broadcastReminders:
+		return "с включёнными напоминаниями"
+	default:
+		return "все пользователи"
+	}
+}
+
+// Telegram ID получателей рассылки
+func broadcastRecipients(target string, days int) ([]int64, error) {
+	var ids []int64
+	query := db.DB.Model(&models.User{})
+	switch target {
+	case broadcastActive:
+		// Активность — по журналу изменений отметок, как и в /admin stats
+		since := time.Now().AddDate(0, 0, -days)
+		query = query.Where("id IN (?)", db.DB.Model(&models.IntakeLogAudit{}).Select("user_id").Where("created_at >= ?", since))
+	case broadcastReminders:
+		query = query.Where("id IN (?)", db.DB.Model(&models.Supplement{}).Select("user_id").Where("reminder_enabled = ?", true))
+	}
+	err := query.Order("telegram_id").Pluck("telegram_id", &ids).Error
+	return ids, err
+}
+
+// Разбирает аудиторию из аргументов команды
+func parseBroadcastTarget(args []string) (target string, days int, ok bool) {
+	if len(args) == 0 {
+		return "", 0, false
+	}
+	switch args[0] {
+	case broadcastAll, broadcastReminders:
+		return args[0], 0, len(args) == 1
+	case broadcastActive:
+		if len(args) != 2 {
+			return "", 0, false
+		}
+		days, err := strconv.Atoi(args[1])
+		if err != nil || days < 1 || days > maxBroadcastDays {
+			return "", 0, false
+		}
+		return broadcastActive, days, true
+	}
+	return "", 0, false
+}
+
+// Начинает черновик: запоминает аудиторию и ждёт текст рассылки
+func startBroadcastDraft(c tele.Context, target string, days int) error {
+	recipients, err := broadcastRecipients(target, days)
+	if err != nil {
+		return err
+	}
+	broadcastDrafts.Lock()
+	broadcastDrafts.seq++
+	broadcastDrafts.m[c.Sender().ID] = &broadcastDraft{Seq: broadcastDrafts.seq, Target: target, Days: days}
+	broadcastDrafts.Unlock()
+	setPendingInput(c.Sender().ID, &PendingInput{Kind: inputBroadcast})
+	return c.Send(fmt.Sprintf("Аудитория: %s, получателей: %d.\nПришли текст рассылки одним сообщением.",
+		broadcastTargetLabel(target, days), len(recipients)), utils.CancelKeyboard())
+}
+
+// /broadcast [аудитория] — рассылка сообщения пользователям (только для администраторов)
+func BroadcastHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		args := c.Args()
+		if len(args) > 0 {
+			target, days, ok := parseBroadcastTarget(args)
+			if !ok {
+				return c.Send(broadcastUsage)
+			}
+			if err := startBroadcastDraft(c, target, days); err != nil {
+				log.Error("Ошибка подготовки рассылки", zap.Error(err))
+				return c.Send("Ошибка при подсчёте получателей.")
+			}
+			return nil
+		}
+		markup := &tele.ReplyMarkup{}
+		markup.Inline(
+			markup.Row(callback.Button(markup, "👥 Всем", "broadcast", broadcastTarget, broadcastAll, "0")),
+			markup.Row(
+				callback.Button(markup, "🔥 Активным за 7 дней", "broadcast", broadcastTarget, broadcastActive, "7"),
+				callback.Button(markup, "🔥 За 30 дней", "broadcast", broadcastTarget, broadcastActive, "30"),
+			),
+			markup.Row(callback.Button(markup, "⏰ С напоминаниями", "broadcast", broadcastTarget, broadcastReminders, "0")),
+		)
+		return c.Send(broadcastUsage+"\n\nКому отправить?", markup)
+	}
+}
+
+// Текст рассылки: показываем предпросмотр с кнопками подтверждения
+func handleBroadcastInput(c tele.Context, log *zap.Logger) error {
+	adminID := c.Sender().ID
+	if !isAdmin(adminID) {
+		return nil
+	}
+	broadcastDrafts.Lock()
+	draft, ok := broadcastDrafts.m[adminID]
+	if ok {
+		draft.Text = c.Text()
+	}
+	broadcastDrafts.Unlock()
+	if !ok {
+		return c.Send("Черновик рассылки не найден, начни заново: /broadcast", utils.MainMenuKeyboard())
+	}
+	recipients, err := broadcastRecipients(draft.Target, draft.Days)
+	if err != nil {
+		log.Error("Ошибка подсчёта получателей рассылки", zap.Error(err))
+		return c.Send("Ошибка при подсчёте получателей.", utils.MainMenuKeyboard())
+	}
+	if err := c.Send("👀 Предпросмотр — так сообщение увидят пользователи:", utils.MainMenuKeyboard()); err != nil {
+		return err
+	}
+	if err := c.Send(draft.Text); err != nil {
+		return err
+	}
+	seq := strconv.Itoa(draft.Seq)
+	markup := &tele.ReplyMarkup{}
+	markup.Inline(markup.Row(
+		callback.Button(markup, "✅ Отправить", "broadcast", broadcastConfirm, seq, "0"),
+		callback.Button(markup, "❌ Отмена", "broadcast", broadcastCancel, seq, "0"),
+	))
+	return c.Send(fmt.Sprintf("Аудитория: %s\nПолучателей: %d\n\nОтправить?", broadcastTargetLabel(draft.Target, draft.Days), len(recipients)), markup)
+}
+
+// Забирает черновик, если кнопка относится к нему
+func takeBroadcastDraft(adminID int64, seq string) (*broadcastDraft, bool) {
+	broadcastDrafts.Lock()
+	defer broadcastDrafts.Unlock()
+	draft, ok := broadcastDrafts.m[adminID]
+	if !ok || strconv.Itoa(draft.Seq) != seq || draft.Text == "" {
+		return nil, false
+	}
+	delete(broadcastDrafts.m, adminID)
+	return draft, true
+}
+
+// Кнопки рассылки: выбор аудитории, подтверждение и отмена
+func HandleBroadcastCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		fields, err := callback.Decode(c.Callback(), 3) // действие, аудитория или номер черновика, дни
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		adminID := c.Sender().ID
+		switch fields[0] {
+		case broadcastTarget:
+			days, _ := strconv.Atoi(fields[2])
+			target, days, ok := parseBroadcastTarget(broadcastTargetArgs(fields[1], days))
+			if !ok {
+				return respondBadCallback(c, log, callback.ErrMalformed)
+			}
+			_ = c.Respond()
+			if err := startBroadcastDraft(c, target, days); err != nil {
+				log.Error("Ошибка подготовки рассылки", zap.Error(err))
+				return c.Send("Ошибка при подсчёте получателей.")
+			}
+			return nil
+		case broadcastCancel:
+			broadcastDrafts.Lock()
+			delete(broadcastDrafts.m, adminID)
+			broadcastDrafts.Unlock()
+			_ = c.Respond(&tele.CallbackResponse{Text: "Рассылка отменена"})
+			return c.Edit("❌ Рассылка отменена.")
+		case broadcastConfirm:
+			draft, ok := takeBroadcastDraft(adminID, fields[1])
+			if !ok {
+				return c.Respond(&tele.CallbackResponse{Text: "Рассылка уже отправлена или отменена"})
+			}
+			recipients, err := broadcastRecipients(draft.Target, draft.Days)
+			if err != nil {
+				log.Error("Ошибка подсчёта получателей рассылки", zap.Error(err))
+				return c.Respond(&tele.CallbackResponse{Text: "Ошибка базы данных"})
+			}
+			job := broadcastJob{AdminID: adminID, Text: draft.Text, Recipients: recipients, Label: broadcastTargetLabel(draft.Target, draft.Days)}
+			if !enqueueBroadcast(b, log, job) {
+				return c.Respond(&tele.CallbackResponse{Text: "Очередь рассылок переполнена, попробуй позже"})
+			}
+			log.Info("Рассылка поставлена в очередь", zap.Int64("admin_id", adminID), zap.String("target", job.Label), zap.Int("recipients", len(recipients)))
+			_ = c.Respond(&tele.CallbackResponse{Text: "Рассылка запущена"})
+			return c.Edit(fmt.Sprintf("📤 Рассылка поставлена в очередь: %d получателей. Пришлю отчёт, когда закончу.", len(recipients)))
+		}
+		return respondBadCallback(c, log, callback.ErrMalformed)
+	}
+}
+
+// Аргументы команды по данным кнопки выбора аудитории
+func broadcastTargetArgs(target string, days int) []string {
+	if target == broadcastActive {
+		return []string{target, strconv.Itoa(days)}
+	}
+	return []string{target}
+}
+
+// Ставит рассылку в очередь; единственный обработчик очереди запускается при первой рассылке
+func enqueueBroadcast(b *tele.Bot, log *zap.Logger, job broadcastJob) bool {
+	broadcastOnce.Do(func() {
+		go runBroadcasts(b, log)
+	})
+	select {
+	case broadcastQueue <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// Отправляет рассылки по одной, не чаще одного сообщения за broadcastInterval
+func runBroadcasts(b *tele.Bot, log *zap.Logger) {
+	ticker := time.NewTicker(broadcastInterval)
+	defer ticker.Stop()
+	for job := range broadcastQueue {
+		started := time.Now()
+		sent, blocked, failed := 0, 0, 0
+		for _, id := range job.Recipients {
+			<-ticker.C
+			err := sendBroadcastMessage(b, id, job.Text)
+			switch {
+			case err == nil:
+				sent++
+			case isBlockedError(err):
+				blocked++
+			default:
+				failed++
+				log.Warn("Ошибка отправки рассылки", zap.Int64("telegram_id", id), zap.Error(err))
+			}
+		}
+		log.Info("Рассылка завершена", zap.Int64("admin_id", job.AdminID), zap.Int("sent", sent), zap.Int("blocked", blocked), zap.Int("failed", failed))
+		report := fmt.Sprintf("📬 Рассылка завершена (%s) за %s\n\nОтправлено: %d\nЗаблокировали бота: %d\nОшибки: %d",
+			job.Label, time.Since(started).Round(time.Second), sent, blocked, failed)
+		if _, err := b.Send(&tele.User{ID: job.AdminID}, report); err != nil {
+			log.Error("Ошибка отправки отчёта о рассылке", zap.Error(err))
+		}
+	}
+}
+
+// Отправляет сообщение, один раз повторяя его после ограничения частоты от Telegram
+func sendBroadcastMessage(b *tele.Bot, telegramID int64, text string) error {
+	_, err := b.Send(&tele.User{ID: telegramID}, text)
+	var flood tele.FloodError
+	if errors.As(err, &flood) {
+		time.Sleep(time.Duration(flood.RetryAfter) * time.Second)
+		_, err = b.Send(&tele.User{ID: telegramID}, text)
+	}
+	return err
+}
+
+// Пользователь заблокировал бота или удалил аккаунт — сообщение не доставить
+func isBlockedError(err error) bool {
+	return errors.Is(err, tele.ErrBlockedByUser) ||
+		errors.Is(err, tele.ErrUserIsDeactivated) ||
+		errors.Is(err, tele.ErrChatNotFound)
+}
@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/callback"
+	"DailyDoseBot/internal/db"
+	"DailyDoseBot/internal/models"
+	"DailyDoseBot/internal/utils"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Сколько добавок можно добавить за один раз
+const maxBulkRows = 30
+
+// Максимальный размер CSV-файла
+const maxBulkFileSize = 256 << 10
+
+// Колонки строки по порядку; обязательны только первые две
+var bulkColumns = []string{"название", "дозировка", "время приёма", "с едой", "дата начала", "срок", "дни недели", "напоминания"}
+
+const bulkHelp = `📋 Массовое добавление
+
+Пришли список — по добавке в строке, поля через «;» — или CSV-файл с теми же колонками:
+название; дозировка; время приёма; с едой; дата начала; срок; дни недели; напоминания
+
+Обязательны название и дозировка, остальное можно опустить:
+• время приёма: утро, день, вечер, любое или «по необходимости» (по умолчанию любое)
+• с едой: да или нет (по умолчанию нет)
+• дата начала: ГГГГ-ММ-ДД или «сегодня» (по умолчанию сегодня)
+• срок: число недель (3), месяцев (2м) или «-» (по умолчанию бессрочно)
+• дни недели: пн, ср, пт или пусто — каждый день; для «по необходимости» — пусто
+• напоминания: 08:00, 20:30 или «нет»; для «по необходимости» — максимум приёмов в день
+
+Пример:
+Витамин D3; 2000 МЕ; утро; да; сегодня; 2м; ; 09:00
+Магний; 400 мг; вечер; нет; ; -; пн, ср, пт; 21:00
+Ибупрофен; 200 мг; по необходимости; да; ; ; ; 3`
+
+// Строка списка: готовая добавка или ошибка
+type bulkRow struct {
+	Line       int
+	Supplement models.Supplement
+	Err        error
+}
+
+// Проверенные добавки, ожидающие подтверждения
+var bulkDrafts = struct {
+	sync.Mutex
+	m map[int64][]models.Supplement
+}{m: make(map[int64][]models.Supplement)}
+
+var errBulkTooMany = fmt.Errorf("за один раз можно добавить не больше %d добавок", maxBulkRows)
+
+// /bulk — добавить несколько добавок списком или CSV-файлом
+func BulkHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		setPendingInput(c.Sender().ID, &PendingInput{Kind: inputBulk})
+		return c.Send(bulkHelp, utils.CancelKeyboard())
+	}
+}
+
+// Разбирает строки списка; comma — разделитель полей
+func parseBulk(r io.Reader, comma rune) ([]bulkRow, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.LazyQuotes = true
+	var rows []bulkRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			rows = append(rows, bulkRow{Line: line, Err: errors.New("не удалось разобрать строку")})
+			continue
+		}
+		if isBlankRecord(record) || (len(rows) == 0 && isBulkHeader(record)) {
+			continue
+		}
+		if len(rows) == maxBulkRows {
+			return nil, errBulkTooMany
+		}
+		supp, err := parseBulkRecord(record)
+		rows = append(rows, bulkRow{Line: line, Supplement: supp, Err: err})
+	}
+	return rows, nil
+}
+
+func isBlankRecord(record []string) bool {
+	for _, f := range record {
+		if strings.TrimSpace(f) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Первая строка CSV с названиями колонок
+func isBulkHeader(record []string) bool {
+	first := strings.ToLower(strings.TrimSpace(record[0]))
+	return first == bulkColumns[0] || first == "name"
+}
+
+// Строка списка в добавку по тем же правилам, что и в мастере /add
+func parseBulkRecord(record []string) (models.Supplement, error) {
+	field := func(i int) string {
+		if i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	if len(record) > len(bulkColumns) {
+		return models.Supplement{}, fmt.Errorf("лишние поля: ожидается не больше %d", len(bulkColumns))
+	}
+	var s models.Supplement
+	var err error
+	if s.Name, err = parseSupplementName(field(0)); err != nil {
+		return s, err
+	}
+	if s.Dosage, err = parseDosage(field(1)); err != nil {
+		return s, err
+	}
+	intakeTime, prn, err := parseIntakeTime(field(2))
+	if err != nil {
+		return s, err
+	}
+	s.IntakeTime = intakeTime
+	s.ScheduleType = models.ScheduleRegular
+	if s.WithFood, err = parseWithFood(field(3)); err != nil {
+		return s, err
+	}
+	if s.StartDate, err = parseStartDate(field(4)); err != nil {
+		return s, err
+	}
+	if s.EndDate, err = parseCourseEnd(field(5), s.StartDate); err != nil {
+		return s, err
+	}
+	days, err := parseWeekdays(field(6))
+	if err != nil {
+		return s, err
+	}
+	times := []string{}
+	if prn {
+		// Как в мастере: по необходимости — без дней недели и напоминаний, но с дневным лимитом
+		if field(6) != "" {
+			return s, errPRNDays
+		}
+		s.ScheduleType = models.SchedulePRN
+		if s.MaxDailyDoses, err = parseMaxDoses(field(7)); err != nil {
+			return s, err
+		}
+	} else {
+		parsed, err := parseReminderTimes(field(7))
+		if err != nil {
+			return s, err
+		}
+		times = append(times, parsed...)
+	}
+	s.ReminderEnabled = len(times) > 0
+	raw, _ := json.Marshal(times)
+	s.ReminderTimes = datatypes.JSON(raw)
+	raw, _ = json.Marshal(days)
+	s.DaysOfWeek = datatypes.JSON(raw)
+	return s, nil
+}
+
+// Короткое описание добавки для предпросмотра
+func bulkSummary(s models.Supplement) string {
+	parts := []string{s.Dosage}
+	if s.IsPRN() {
+		parts = append(parts, prnScheduleText(s))
+	} else {
+		parts = append(parts, daysOfWeekText(s))
+		var times []string
+		_ = json.Unmarshal(s.ReminderTimes, &times)
+		if len(times) > 0 {
+			parts = append(parts, "⏰ "+strings.Join(times, ", "))
+		}
+	}
+	if s.WithFood {
+		parts = append(parts, "с едой")
+	}
+	if s.EndDate != nil {
+		parts = append(parts, fmt.Sprintf("%s — %s", s.StartDate.Format("02.01.2006"), s.EndDate.Format("02.01.2006")))
+	} else {
+		parts = append(parts, "с "+s.StartDate.Format("02.01.2006"))
+	}
+	return s.Name + ": " + strings.Join(parts, ", ")
+}
+
+// Показывает предпросмотр с ошибками по строкам и запоминает корректные добавки
+func previewBulk(c tele.Context, input *PendingInput, rows []bulkRow) error {
+	var sb strings.Builder
+	var valid []models.Supplement
+	for _, r := range rows {
+		if r.Err != nil {
+			sb.WriteString(fmt.Sprintf("❌ %d. %s\n", r.Line, r.Err))
+			continue
+		}
+		valid = append(valid, r.Supplement)
+		sb.WriteString(fmt.Sprintf("✅ %d. %s\n", r.Line, bulkSummary(r.Supplement)))
+	}
+	if len(rows) == 0 {
+		setPendingInput(c.Sender().ID, input)
+		return c.Send("В списке нет ни одной добавки. Пришли список ещё раз или нажми «❌ Отмена».", utils.CancelKeyboard())
+	}
+	if len(valid) == 0 {
+		setPendingInput(c.Sender().ID, input)
+		return c.Send(sb.String()+"\nНи одна строка не прошла проверку. Исправь и пришли список ещё раз.", utils.CancelKeyboard())
+	}
+	bulkDrafts.Lock()
+	bulkDrafts.m[c.Sender().ID] = valid
+	bulkDrafts.Unlock()
+
+	if err := c.Send("Список проверен", utils.MainMenuKeyboard()); err != nil {
+		return err
+	}
+	if skipped := len(rows) - len(valid); skipped > 0 {
+		sb.WriteString(fmt.Sprintf("\nСтроки с ошибками (%d) будут пропущены.", skipped))
+	}
+	markup := &tele.ReplyMarkup{}
+	markup.Inline(markup.Row(
+		callback.Button(markup, fmt.Sprintf("✅ Добавить (%d)", len(valid)), "bulk", "y"),
+		callback.Button(markup, "❌ Отмена", "bulk", "n"),
+	))
+	return c.Send("📋 Предпросмотр:\n\n"+sb.String(), markup)
+}
+
+// Список, присланный текстом: поля через «;»
+func handleBulkText(c tele.Context, log *zap.Logger, input *PendingInput) error {
+	rows, err := parseBulk(strings.NewReader(c.Text()), ';')
+	if err != nil {
+		setPendingInput(c.Sender().ID, input)
+		return c.Send("❌ "+err.Error(), utils.CancelKeyboard())
+	}
+	return previewBulk(c, input, rows)
+}
+
+// Список CSV-файлом
+func handleBulkDocument(c tele.Context, b *tele.Bot, log *zap.Logger, input *PendingInput) error {
+	data, err := downloadDocument(b, c.Message().Document, maxBulkFileSize)
+	if err != nil {
+		log.Warn("Не удалось скачать CSV для массового добавления", zap.Error(err))
+		setPendingInput(c.Sender().ID, input)
+		return c.Send("Не удалось получить файл: "+err.Error(), utils.CancelKeyboard())
+	}
+	rows, err := parseBulkFile(data)
+	if err != nil {
+		setPendingInput(c.Sender().ID, input)
+		return c.Send("❌ "+err.Error(), utils.CancelKeyboard())
+	}
+	return previewBulk(c, input, rows)
+}
+
+// Разбирает CSV-файл: разделитель — запятая или точка с запятой, смотря чего больше в первой строке
+func parseBulkFile(data []byte) ([]bulkRow, error) {
+	// Excel сохраняет CSV с BOM
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	comma := ','
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		comma = ';'
+	}
+	return parseBulk(bytes.NewReader(data), comma)
+}
+
+// Подтверждение массового добавления: все добавки создаются одной транзакцией
+func HandleBulkCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		fields, err := callback.Decode(c.Callback(), 1) // y — добавить, n — отменить
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		bulkDrafts.Lock()
+		supplements, ok := bulkDrafts.m[c.Sender().ID]
+		delete(bulkDrafts.m, c.Sender().ID)
+		bulkDrafts.Unlock()
+		if fields[0] != "y" {
+			_ = c.Respond()
+			return c.Edit("Массовое добавление отменено.")
+		}
+		if !ok {
+			return c.Respond(&tele.CallbackResponse{Text: "Список уже добавлен или устарел, отправь /bulk заново"})
+		}
+		var user models.User
+		if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Пользователь не найден"})
+		}
+		for i := range supplements {
+			supplements[i].UserID = user.ID
+		}
+		err = db.DB.Transaction(func(tx *gorm.DB) error {
+			// Select("*"): иначе выключенные напоминания заменятся значением по умолчанию
+			return tx.Select("*").Create(&supplements).Error
+		})
+		if err != nil {
+			log.Error("Ошибка массового добавления", zap.Error(err))
+			return c.Respond(&tele.CallbackResponse{Text: "Ошибка при сохранении, ничего не добавлено"})
+		}
+		log.Info("Массовое добавление", zap.Int64("telegram_id", c.Sender().ID), zap.Int("count", len(supplements)))
+		_ = c.Respond()
+		return c.Edit(fmt.Sprintf("✅ Добавлено добавок: %d. Посмотреть их можно в /list", len(supplements)))
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Краткая сводка строки для сравнения: номер, название и ошибка
+func bulkLines(rows []bulkRow) []string {
+	var out []string
+	for _, r := range rows {
+		if r.Err != nil {
+			out = append(out, fmt.Sprintf("%d: %v", r.Line, r.Err))
+		} else {
+			out = append(out, fmt.Sprintf("%d: %s", r.Line, r.Supplement.Name))
+		}
+	}
+	return out
+}
+
+func TestParseBulk(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "список с пустыми строками",
+			input: "Витамин D3; 2000 МЕ; утро; да; сегодня; 2м; ; 09:00\n\nМагний; 400 мг; вечер; нет; ; -; пн, ср, пт; 21:00\n ; ;\n",
+			want:  []string{"1: Витамин D3", "3: Магний"},
+		},
+		{
+			name:  "заголовок пропускается",
+			input: "название; дозировка; время приёма\nЦинк; 15 мг\n",
+			want:  []string{"2: Цинк"},
+		},
+		{
+			name:  "заголовок не первой строкой — обычная строка",
+			input: "Цинк; 15 мг\nname; dosage\n",
+			want:  []string{"1: Цинк", "2: name"},
+		},
+		{
+			name:  "ошибки по строкам",
+			input: "Цинк; 15 мг\n; 1 таб.\nЖелезо; 1 таб.; ночь\nМагний; 1 таб.; ; ; ; ; ; 08:15\nОмега; 1; ; ; ; ; ; ; лишнее\n",
+			want: []string{"1: Цинк", "2: " + errNameEmpty.Error(), "3: " + errIntakeTime.Error(),
+				"4: " + errReminderStep.Error(), "5: лишние поля: ожидается не больше 8"},
+		},
+		{
+			name:  "поле в кавычках на нескольких строках",
+			input: "\"Омега-3;\nрыбий жир\"; 1 капсула\nЦинк; 15 мг\n",
+			want:  []string{"1: Омега-3;\nрыбий жир", "3: Цинк"},
+		},
+		{
+			name:  "по необходимости с днями недели",
+			input: "Ибупрофен; 200 мг; по необходимости; да; ; ; пн, ср; 3\n",
+			want:  []string{"1: " + errPRNDays.Error()},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := parseBulk(strings.NewReader(tt.input), ';')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := bulkLines(rows); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("parseBulk() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBulkRecordPRN(t *testing.T) {
+	rows, err := parseBulk(strings.NewReader("Ибупрофен; 200 мг; по необходимости; да; 2026-10-19; ; ; 3\n"), ';')
+	if err != nil || len(rows) != 1 || rows[0].Err != nil {
+		t.Fatalf("parseBulk() = %+v, %v", rows, err)
+	}
+	s := rows[0].Supplement
+	if !s.IsPRN() || s.MaxDailyDoses != 3 || s.ReminderEnabled || string(s.ReminderTimes) != "[]" || string(s.DaysOfWeek) != "[0,1,2,3,4,5,6]" || !s.WithFood {
+		t.Errorf("добавка по необходимости: %+v", s)
+	}
+}
+
+func TestParseBulkRecordRegular(t *testing.T) {
+	rows, err := parseBulk(strings.NewReader("Магний; 400 мг; вечер; нет; 2026-10-19; 3; пт, пн; 21:00, 09:00\n"), ';')
+	if err != nil || len(rows) != 1 || rows[0].Err != nil {
+		t.Fatalf("parseBulk() = %+v, %v", rows, err)
+	}
+	s := rows[0].Supplement
+	if s.ScheduleType != models.ScheduleRegular || s.IntakeTime != "evening" || string(s.DaysOfWeek) != "[0,4]" ||
+		string(s.ReminderTimes) != `["21:00","09:00"]` || !s.ReminderEnabled || s.EndDate == nil || s.EndDate.Format(logDateFormat) != "20261109" {
+		t.Errorf("добавка по расписанию: %+v", s)
+	}
+}
+
+// Больше maxBulkRows добавок — ошибка всего списка; пустые строки и заголовок не считаются
+func TestParseBulkLimit(t *testing.T) {
+	list := func(n int) string {
+		var sb strings.Builder
+		sb.WriteString("название;дозировка\n")
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(&sb, "Добавка %d; 1 таб.\n\n", i)
+		}
+		return sb.String()
+	}
+	rows, err := parseBulk(strings.NewReader(list(maxBulkRows)), ';')
+	if err != nil || len(rows) != maxBulkRows {
+		t.Errorf("%d строк: %d, %v", maxBulkRows, len(rows), err)
+	}
+	if _, err := parseBulk(strings.NewReader(list(maxBulkRows+1)), ';'); !errors.Is(err, errBulkTooMany) {
+		t.Errorf("%d строк: err = %v, want %v", maxBulkRows+1, err, errBulkTooMany)
+	}
+}
+
+func TestParseBulkFile(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{"запятые", "name,dosage,intake time\r\nЦинк,15 мг,утро\r\n\"Омега-3, рыбий жир\",1 капсула\r\n", []string{"2: Цинк", "3: Омега-3, рыбий жир"}},
+		{"точка с запятой из Excel с BOM", "\ufeffназвание;дозировка;с едой\r\nМагний;400 мг,5 таб.\r\n", []string{"2: Магний"}},
+		// В первой строке запятых больше — разделитель запятая, даже если дальше встречается «;»
+		{"разделитель по первой строке", "Цинк,15 мг,утро,да\nМагний;400 мг\n", []string{"1: Цинк", "2: " + errDosageEmpty.Error()}},
+		{"BOM без заголовка", "\ufeffЦинк;15 мг\n", []string{"1: Цинк"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := parseBulkFile([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := bulkLines(rows); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("parseBulkFile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
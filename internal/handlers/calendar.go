@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/callback"
+	"DailyDoseBot/internal/db"
+	"DailyDoseBot/internal/ical"
+	"DailyDoseBot/internal/models"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+	"gorm.io/gorm"
+)
+
+// Название календаря в приложениях
+const calendarName = "DailyDose — приём добавок"
+
+// Длина секрета ссылки подписки в байтах
+const calendarTokenBytes = 20
+
+// Календарь добавок пользователя в формате .ics
+func buildCalendar(user models.User) (*bytes.Buffer, error) {
+	var supplements []models.Supplement
+	if err := db.DB.Where("user_id = ?", user.ID).Order("created_at").Find(&supplements).Error; err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := ical.Write(&buf, calendarName, supplements, time.Now()); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// Подписка включена, если заданы адрес HTTP-сервера и внешний адрес для ссылок:
+// адрес прослушивания (":8080", "0.0.0.0:8080") снаружи недоступен
+func calendarFeedEnabled() bool {
+	return appConfig != nil && appConfig.CalendarAddr != "" && appConfig.CalendarURL != ""
+}
+
+// Ссылка подписки по внешнему адресу сервера
+func calendarFeedURL(token string) string {
+	return appConfig.CalendarURL + "/calendar/" + token + ".ics"
+}
+
+// Выдаёт новый секрет ссылки подписки; старая ссылка перестаёт работать
+func rotateCalendarToken(user *models.User) error {
+	raw := make([]byte, calendarTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(raw)
+	if err := db.DB.Model(user).Update("calendar_token", token).Error; err != nil {
+		return err
+	}
+	user.CalendarToken = &token
+	return nil
+}
+
+// Текст и кнопка ссылки подписки
+func calendarFeedMessage(user models.User) (string, *tele.ReplyMarkup) {
+	markup := &tele.ReplyMarkup{}
+	markup.Inline(markup.Row(callback.Button(markup, "🔄 Новая ссылка", "calendar", "rotate")))
+	return "🔗 Ссылка для подписки в календаре (Google, Apple, Outlook — «Добавить по URL»):\n" + calendarFeedURL(*user.CalendarToken) +
+		"\n\nКалендарь обновится сам, когда изменится расписание. Не показывай ссылку другим; если она попала к посторонним — выпусти новую.", markup
+}
+
+// /calendar — расписание добавок файлом .ics и ссылка подписки
+func CalendarHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		var user models.User
+		if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+			return c.Send("Пользователь не найден.")
+		}
+		buf, err := buildCalendar(user)
+		if err != nil {
+			log.Error("Ошибка построения календаря .ics", zap.Error(err))
+			return c.Send("Ошибка при построении календаря.")
+		}
+		if err := c.Send(&tele.Document{
+			File:     tele.FromReader(buf),
+			FileName: "dailydose_" + nowDate().Format(logDateFormat) + ".ics",
+			MIME:     "text/calendar",
+			Caption:  "📆 Расписание приёма: открой файл, чтобы добавить события с напоминаниями в календарь. Добавки по необходимости и завершённые курсы не включаются.",
+		}); err != nil {
+			return err
+		}
+		if !calendarFeedEnabled() {
+			return nil
+		}
+		if user.CalendarToken == nil {
+			if err := rotateCalendarToken(&user); err != nil {
+				log.Error("Ошибка выдачи ссылки календаря", zap.Error(err))
+				return c.Send("Не удалось создать ссылку подписки.")
+			}
+		}
+		msg, markup := calendarFeedMessage(user)
+		return c.Send(msg, markup)
+	}
+}
+
+// Кнопка перевыпуска ссылки подписки
+func HandleCalendarCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		fields, err := callback.Decode(c.Callback(), 1) // действие
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		if fields[0] != "rotate" || !calendarFeedEnabled() {
+			return respondBadCallback(c, log, callback.ErrMalformed)
+		}
+		var user models.User
+		if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Пользователь не найден"})
+		}
+		if err := rotateCalendarToken(&user); err != nil {
+			log.Error("Ошибка перевыпуска ссылки календаря", zap.Error(err))
+			return c.Respond(&tele.CallbackResponse{Text: "Ошибка, попробуй позже"})
+		}
+		_ = c.Respond(&tele.CallbackResponse{Text: "Старая ссылка больше не работает"})
+		msg, markup := calendarFeedMessage(user)
+		return c.Edit(msg, markup)
+	}
+}
+
+// Запускает HTTP-сервер подписки на календарь, если он включён в настройках
+func StartCalendarFeed(log *zap.Logger) {
+	if appConfig != nil && appConfig.CalendarAddr != "" && appConfig.CalendarURL == "" {
+		log.Warn("CALENDAR_ADDR задан без CALENDAR_URL, подписка на календарь выключена")
+	}
+	if !calendarFeedEnabled() {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /calendar/{file}", calendarFeedHandler(log))
+	server := &http.Server{
+		Addr:              appConfig.CalendarAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      10 * time.Second,
+	}
+	go func() {
+		log.Info("Сервер подписки на календарь запущен", zap.String("addr", appConfig.CalendarAddr))
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("Сервер подписки на календарь остановлен", zap.Error(err))
+		}
+	}()
+}
+
+// GET /calendar/<токен>.ics — календарь владельца токена
+func calendarFeedHandler(log *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		file := r.PathValue("file")
+		token, ok := strings.CutSuffix(file, ".ics")
+		if !ok || len(token) != hex.EncodedLen(calendarTokenBytes) {
+			http.NotFound(w, r)
+			return
+		}
+		var user models.User
+		if err := db.DB.First(&user, "calendar_token = ?", token).Error; err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Error("Ошибка поиска владельца календаря", zap.Error(err))
+				http.Error(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+			http.NotFound(w, r)
+			return
+		}
+		buf, err := buildCalendar(user)
+		if err != nil {
+			log.Error("Ошибка построения календаря .ics", zap.Error(err))
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		_, _ = buf.WriteTo(w)
+	}
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/config"
+	"testing"
+)
+
+// Ссылка строится только по внешнему адресу: адрес прослушивания снаружи недоступен
+func TestCalendarFeedURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		addr, url   string
+		wantEnabled bool
+		wantURL     string
+	}{
+		{"выключено", "", "", false, ""},
+		{"нет внешнего адреса", "0.0.0.0:8080", "", false, ""},
+		{"только внешний адрес", "", "https://bot.example.com", false, ""},
+		{"включено", ":8080", "https://bot.example.com", true, "https://bot.example.com/calendar/abc.ics"},
+		{"внешний адрес с путём", "0.0.0.0:8080", "https://example.com/dose", true, "https://example.com/dose/calendar/abc.ics"},
+	}
+	prev := appConfig
+	t.Cleanup(func() { appConfig = prev })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appConfig = &config.Config{CalendarAddr: tt.addr, CalendarURL: tt.url}
+			if got := calendarFeedEnabled(); got != tt.wantEnabled {
+				t.Fatalf("calendarFeedEnabled() = %v, want %v", got, tt.wantEnabled)
+			}
+			if tt.wantEnabled {
+				if got := calendarFeedURL("abc"); got != tt.wantURL {
+					t.Errorf("calendarFeedURL() = %q, want %q", got, tt.wantURL)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/callback"
+	"errors"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// Ответ на нажатие кнопки с устаревшими, повреждёнными или поддельными данными
+func respondBadCallback(c tele.Context, log *zap.Logger, err error) error {
+	switch {
+	case errors.Is(err, callback.ErrOutdated):
+		return c.Respond(&tele.CallbackResponse{Text: "Кнопка устарела, открой меню заново"})
+	case errors.Is(err, callback.ErrForged):
+		log.Warn("security: поддельные данные кнопки",
+			zap.Int64("telegram_id", c.Sender().ID),
+			zap.String("unique", c.Callback().Unique),
+			zap.String("data", c.Callback().Data))
+	}
+	return c.Respond(&tele.CallbackResponse{Text: "Ошибка данных"})
+}
+
+// Ответ при ошибке загрузки добавки по данным кнопки
+func respondSupplementError(c tele.Context, err error) error {
+	if errors.Is(err, errForbidden) {
+		return c.Respond(&tele.CallbackResponse{Text: "Нет доступа"})
+	}
+	return c.Respond(&tele.CallbackResponse{Text: "Добавка не найдена"})
+}
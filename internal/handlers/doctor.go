@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/callback"
+	"DailyDoseBot/internal/db"
+	"DailyDoseBot/internal/doctor"
+	"DailyDoseBot/internal/models"
+	"DailyDoseBot/internal/schedule"
+	"DailyDoseBot/internal/utils"
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// Периоды отчёта для врача в днях; 0 — ввести свой период
+var doctorPeriods = []struct {
+	Label string
+	Days  int
+}{
+	{"30 дней", 30},
+	{"90 дней", 90},
+	{"180 дней", 180},
+	{"📅 Свой период", 0},
+}
+
+// Собирает данные отчёта для врача за период включительно
+func collectDoctorReport(user models.User, from, to time.Time) (doctor.Report, error) {
+	r := doctor.Report{Patient: user.Name, From: from, To: to, Generated: time.Now()}
+	// Добавки, курс которых пересекается с периодом
+	var supplements []models.Supplement
+	if err := db.DB.Where("user_id = ? AND start_date <= ? AND (end_date IS NULL OR end_date >= ?)", user.ID, to, from).
+		Order("start_date, name").Find(&supplements).Error; err != nil {
+		return r, err
+	}
+	var entries []models.IntakeLog
+	if err := db.DB.Where("user_id = ? AND intake_date BETWEEN ? AND ?", user.ID, from, to).
+		Order("intake_date, intake_time").Find(&entries).Error; err != nil {
+		return r, err
+	}
+	logs := make(map[string]models.IntakeLog, len(entries))
+	for _, e := range entries {
+		logs[doseKey(e.SupplementID, e.IntakeDate, e.IntakeTime)] = e
+	}
+
+	today := nowDate()
+	index := make(map[uuid.UUID]int, len(supplements))
+	names := make(map[uuid.UUID]string, len(supplements))
+	for i, s := range supplements {
+		index[s.ID] = i
+		names[s.ID] = s.Name
+		item := doctor.Supplement{
+			Name:     s.Name,
+			Dosage:   s.Dosage,
+			Schedule: doctorScheduleText(s),
+			WithFood: s.WithFood,
+			Start:    s.StartDate,
+			End:      s.EndDate,
+			PRN:      s.IsPRN(),
+			Status:   "Принимается",
+		}
+		switch {
+		case s.IsPRN():
+			item.Status = "По необходимости"
+			item.Taken = int(prnTakenCount(logs, s.ID))
+		case s.Completed || (s.EndDate != nil && s.EndDate.Before(today)):
+			item.Status = "Курс завершён"
+		}
+		r.Supplements = append(r.Supplements, item)
+	}
+
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		r.Days = append(r.Days, doctor.Day{Date: day})
+	}
+	for _, occ := range schedule.ExpandAll(supplements, from, to) {
+		portion := logs[doseKey(occ.SupplementID, occ.Date, occ.Time)].Portion()
+		d := &r.Days[int(occ.Date.Sub(from).Hours()/24)]
+		d.Planned++
+		d.Done += portion
+		s := &r.Supplements[index[occ.SupplementID]]
+		s.Planned++
+		s.Done += portion
+	}
+
+	// Заметки и побочные эффекты к приёмам за период
+	counts := make(map[string]int)
+	for _, e := range entries {
+		codes := decodeSideEffects(e)
+		if e.Note == "" && len(codes) == 0 {
+			continue
+		}
+		note := doctor.Note{Date: e.IntakeDate, Time: e.IntakeTime, Supplement: names[e.SupplementID], Text: e.Note}
+		for _, code := range codes {
+			label := sideEffectLabel(code)
+			note.SideEffects = append(note.SideEffects, label)
+			counts[label]++
+		}
+		r.Notes = append(r.Notes, note)
+	}
+	for label, n := range counts {
+		r.SideEffects = append(r.SideEffects, doctor.SideEffect{Label: label, Count: n})
+	}
+	sort.Slice(r.SideEffects, func(i, j int) bool {
+		if r.SideEffects[i].Count != r.SideEffects[j].Count {
+			return r.SideEffects[i].Count > r.SideEffects[j].Count
+		}
+		return r.SideEffects[i].Label < r.SideEffects[j].Label
+	})
+	return r, nil
+}
+
+// Дни и время приёма одной строкой
+func doctorScheduleText(s models.Supplement) string {
+	if s.IsPRN() {
+		return prnScheduleText(s)
+	}
+	text := daysOfWeekText(s)
+	if text == "—" {
+		text = "Каждый день"
+	}
+	if times := schedule.Times(s); times[0] != "" {
+		text += ", " + strings.Join(times, ", ")
+	}
+	return text
+}
+
+// Отчёт для врача HTML-документом
+func sendDoctorReport(c tele.Context, log *zap.Logger, from, to time.Time) error {
+	var user models.User
+	if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+		return c.Send("Пользователь не найден.")
+	}
+	report, err := collectDoctorReport(user, from, to)
+	if err != nil {
+		log.Error("Ошибка сбора отчёта для врача", zap.Error(err))
+		return c.Send("Ошибка при подготовке отчёта.")
+	}
+	var buf bytes.Buffer
+	if err := doctor.Render(&buf, report); err != nil {
+		log.Error("Ошибка формирования отчёта для врача", zap.Error(err))
+		return c.Send("Ошибка при подготовке отчёта.")
+	}
+	return c.Send(&tele.Document{
+		File:     tele.FromReader(&buf),
+		FileName: fmt.Sprintf("dailydose_report_%s-%s.html", from.Format(logDateFormat), to.Format(logDateFormat)),
+		MIME:     "text/html",
+		Caption:  fmt.Sprintf("🩺 Отчёт о приёме добавок с %s по %s. Открой файл в браузере — его можно показать с экрана или распечатать.", from.Format("02.01.2006"), to.Format("02.01.2006")),
+	}, utils.MainMenuKeyboard())
+}
+
+// /doctor — отчёт для визита к врачу за выбранный период
+func DoctorHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		markup := &tele.ReplyMarkup{}
+		var row []tele.Btn
+		for _, p := range doctorPeriods {
+			row = append(row, callback.Button(markup, p.Label, "doctor", strconv.Itoa(p.Days)))
+		}
+		markup.Inline(markup.Row(row[:len(row)-1]...), markup.Row(row[len(row)-1]))
+		return c.Send("🩺 Отчёт для врача: добавки и курсы, соблюдение, календарь приёмов, заметки и побочные эффекты.\n\nЗа какой период?", markup)
+	}
+}
+
+// Кнопка периода отчёта для врача
+func HandleDoctorCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		fields, err := callback.Decode(c.Callback(), 1) // дней, 0 — свой период
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		days, err := strconv.Atoi(fields[0])
+		if err != nil || days < 0 || days > maxStatsDays {
+			return respondBadCallback(c, log, callback.ErrMalformed)
+		}
+		_ = c.Respond()
+		if days == 0 {
+			setPendingInput(c.Sender().ID, &PendingInput{Kind: inputDoctorRange})
+			return c.Send("Введи период в формате ДД.ММ.ГГГГ-ДД.ММ.ГГГГ, например 01.09.2026-30.09.2026", utils.CancelKeyboard())
+		}
+		to := nowDate()
+		return sendDoctorReport(c, log, to.AddDate(0, 0, 1-days), to)
+	}
+}
+
+// Обрабатывает введённый период отчёта для врача
+func handleDoctorRangeInput(c tele.Context, log *zap.Logger) error {
+	from, to, err := parseStatsRange(c.Text())
+	if err != nil {
+		setPendingInput(c.Sender().ID, &PendingInput{Kind: inputDoctorRange})
+		return c.Send(fmt.Sprintf("Не понял период. Нужен формат ДД.ММ.ГГГГ-ДД.ММ.ГГГГ, не длиннее %d дней и не в будущем.", maxStatsDays), utils.CancelKeyboard())
+	}
+	return sendDoctorReport(c, log, from, to)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/callback"
+	"DailyDoseBot/internal/db"
+	"DailyDoseBot/internal/export"
+	"DailyDoseBot/internal/models"
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// Периоды выгрузки в днях; 0 — вся история
+var exportPeriods = []struct {
+	Label string
+	Days  int
+}{
+	{"30 дней", 30},
+	{"90 дней", 90},
+	{"Год", 365},
+	{"Всё время", 0},
+}
+
+// /export — выбор периода выгрузки истории в CSV
+func ExportHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		markup := &tele.ReplyMarkup{}
+		var row []tele.Btn
+		for _, p := range exportPeriods {
+			row = append(row, callback.Button(markup, p.Label, "export", strconv.Itoa(p.Days)))
+		}
+		markup.Inline(markup.Row(row...))
+		return c.Send("📤 Выгрузка в CSV: история приёмов и список добавок.\n\nЗа какой период выгрузить историю?", markup)
+	}
+}
+
+// Кнопка периода: отправляет два CSV-файла
+func HandleExportCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		fields, err := callback.Decode(c.Callback(), 1) // дней, 0 — всё время
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		days, err := strconv.Atoi(fields[0])
+		if err != nil || days < 0 {
+			return respondBadCallback(c, log, callback.ErrMalformed)
+		}
+		var user models.User
+		if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Пользователь не найден"})
+		}
+		intakes, supplements, err := buildExportCSV(user, days)
+		if err != nil {
+			log.Error("Ошибка выгрузки CSV", zap.Error(err))
+			return c.Respond(&tele.CallbackResponse{Text: "Ошибка при выгрузке"})
+		}
+		_ = c.Respond()
+		period := "за всё время"
+		if days > 0 {
+			period = fmt.Sprintf("за %d дн.", days)
+		}
+		today := nowDate().Format(logDateFormat)
+		if err := c.Send(&tele.Document{
+			File:     tele.FromReader(intakes),
+			FileName: "dailydose_intakes_" + today + ".csv",
+			MIME:     "text/csv",
+			Caption:  "📋 История приёмов " + period,
+		}); err != nil {
+			return err
+		}
+		return c.Send(&tele.Document{
+			File:     tele.FromReader(supplements),
+			FileName: "dailydose_supplements_" + today + ".csv",
+			MIME:     "text/csv",
+			Caption:  "💊 Добавки с расписанием",
+		})
+	}
+}
+
+// Готовит CSV истории за последние days дней (0 — вся) и CSV добавок
+func buildExportCSV(user models.User, days int) (intakes, supplements *bytes.Buffer, err error) {
+	var supps []models.Supplement
+	if err := db.DB.Where("user_id = ?", user.ID).Order("created_at").Find(&supps).Error; err != nil {
+		return nil, nil, err
+	}
+	query := db.DB.Where("user_id = ?", user.ID)
+	if days > 0 {
+		query = query.Where("intake_date >= ?", nowDate().AddDate(0, 0, 1-days))
+	}
+	var logs []models.IntakeLog
+	if err := query.Order("intake_date, intake_time, created_at").Find(&logs).Error; err != nil {
+		return nil, nil, err
+	}
+
+	byID := make(map[uuid.UUID]models.Supplement, len(supps))
+	for _, s := range supps {
+		byID[s.ID] = s
+	}
+	rows := make([]export.IntakeRow, 0, len(logs))
+	for _, l := range logs {
+		rows = append(rows, export.IntakeRow{Log: l, Supplement: byID[l.SupplementID]})
+	}
+
+	intakes, supplements = &bytes.Buffer{}, &bytes.Buffer{}
+	if err := export.WriteIntakes(intakes, rows); err != nil {
+		return nil, nil, err
+	}
+	if err := export.WriteSupplements(supplements, supps); err != nil {
+		return nil, nil, err
+	}
+	return intakes, supplements, nil
+}
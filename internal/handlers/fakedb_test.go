@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/db"
+	"reflect"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/logger"
+)
+
+// База для тестов без PostgreSQL: запросы только строятся (DryRun) и считаются,
+// а результаты SELECT подставляются из заготовок по типу назначения.
+type fakeDB struct {
+	Queries int
+	SQL     []string // тексты выполненных SELECT
+	rows    map[reflect.Type]reflect.Value
+}
+
+// Подменяет db.DB на время теста; rows — срезы моделей, которые вернут запросы
+func useFakeDB(t testing.TB, rows ...interface{}) *fakeDB {
+	t.Helper()
+	f := &fakeDB{rows: make(map[reflect.Type]reflect.Value)}
+	for _, r := range rows {
+		v := reflect.ValueOf(r)
+		f.rows[v.Type()] = v
+	}
+	conn, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost dbname=test sslmode=disable"}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Callback().Query().Replace("gorm:query", f.query); err != nil {
+		t.Fatal(err)
+	}
+	prev := db.DB
+	db.DB = conn
+	t.Cleanup(func() { db.DB = prev })
+	return f
+}
+
+func (f *fakeDB) query(tx *gorm.DB) {
+	f.Queries++
+	callbacks.BuildQuerySQL(tx)
+	f.SQL = append(f.SQL, tx.Statement.SQL.String())
+	dest := reflect.ValueOf(tx.Statement.Dest)
+	if dest.Kind() != reflect.Ptr {
+		return
+	}
+	target := dest.Elem()
+	if v, ok := f.rows[target.Type()]; ok {
+		target.Set(v)
+		tx.RowsAffected = int64(v.Len())
+		return
+	}
+	// Одна запись (First): первая из заготовок того же типа
+	if v, ok := f.rows[reflect.SliceOf(target.Type())]; ok && v.Len() > 0 {
+		target.Set(v.Index(0))
+		tx.RowsAffected = 1
+		return
+	}
+	if tx.Statement.RaiseErrorOnNotFound {
+		_ = tx.AddError(gorm.ErrRecordNotFound)
+	}
+}
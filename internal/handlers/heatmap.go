@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/callback"
+	"DailyDoseBot/internal/db"
+	"DailyDoseBot/internal/heatmap"
+	"DailyDoseBot/internal/models"
+	"bytes"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// Формат месяца в данных кнопок календаря
+const heatmapMonthFormat = "200601"
+
+var monthNamesRu = []string{"Январь", "Февраль", "Март", "Апрель", "Май", "Июнь", "Июль", "Август", "Сентябрь", "Октябрь", "Ноябрь", "Декабрь"}
+
+// Рисует календарь выполнения за месяц: картинка, подпись и кнопки соседних месяцев
+func buildHeatmap(user models.User, month time.Time) (*tele.Photo, *tele.ReplyMarkup, error) {
+	today := nowDate()
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, -1)
+	if to.After(today) {
+		to = today
+	}
+	stats, err := buildPeriodStats(user, from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	days := make([]heatmap.Day, 0, len(stats.Days))
+	planned, fullDays, activeDays := 0, 0, 0
+	done := 0.0
+	for _, d := range stats.Days {
+		day := heatmap.Day{Date: d.Date, Planned: d.Planned > 0}
+		if d.Planned > 0 {
+			day.Ratio = d.Done / float64(d.Planned)
+			activeDays++
+			if d.Taken == d.Planned {
+				fullDays++
+			}
+		}
+		planned += d.Planned
+		done += d.Done
+		days = append(days, day)
+	}
+	var buf bytes.Buffer
+	if err := heatmap.Render(&buf, from, days); err != nil {
+		return nil, nil, err
+	}
+
+	caption := fmt.Sprintf("🗓 %s %d\n", monthNamesRu[from.Month()-1], from.Year())
+	if planned == 0 {
+		caption += "Приёмов не было запланировано."
+	} else {
+		caption += fmt.Sprintf("✅ Полностью выполнено: %d/%d дней (%d%%)\n💊 Выполнение плана: %d%%",
+			fullDays, activeDays, percentOf(float64(fullDays), activeDays), percentOf(done, planned))
+	}
+	photo := &tele.Photo{File: tele.FromReader(&buf), Caption: caption}
+
+	markup := &tele.ReplyMarkup{}
+	nav := []tele.Btn{callback.Button(markup, "⬅️", "stats_map", from.AddDate(0, -1, 0).Format(heatmapMonthFormat))}
+	if next := from.AddDate(0, 1, 0); !next.After(today) {
+		nav = append(nav, callback.Button(markup, "➡️", "stats_map", next.Format(heatmapMonthFormat)))
+	}
+	markup.Inline(markup.Row(nav...))
+	return photo, markup, nil
+}
+
+// Callback-хендлер кнопки "Календарь" и листания месяцев на картинке
+func HandleStatsHeatmapCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		fields, err := callback.Decode(c.Callback(), 1) // месяц
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		month, err := time.Parse(heatmapMonthFormat, fields[0])
+		if err != nil {
+			return respondBadCallback(c, log, callback.ErrMalformed)
+		}
+		if month.After(nowDate()) {
+			return c.Respond(&tele.CallbackResponse{Text: "Этот месяц ещё не наступил"})
+		}
+		var user models.User
+		if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Пользователь не найден"})
+		}
+		photo, markup, err := buildHeatmap(user, month)
+		if err != nil {
+			log.Error("Ошибка построения календаря", zap.Error(err))
+			return c.Respond(&tele.CallbackResponse{Text: "Ошибка при построении календаря"})
+		}
+		// Листание на самой картинке меняет её, из /stats отправляем новую
+		if c.Message() != nil && c.Message().Photo != nil {
+			err = c.Edit(photo, markup)
+		} else {
+			err = c.Send(photo, markup)
+		}
+		if err != nil {
+			log.Warn("Не удалось отправить календарь", zap.Error(err))
+		}
+		return c.Respond()
+	}
+}
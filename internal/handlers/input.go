@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/utils"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// Виды ожидаемого текстового ввода вне мастера добавления
+const (
+	inputTakenAt     = "taken_at"     // фактическое время приёма
+	inputNote        = "note"         // заметка к приёму
+	inputStatsRange  = "stats_range"  // свой период /stats
+	inputReportTime  = "report_time"  // время отчёта
+	inputTimezone    = "timezone"     // часовой пояс пользователя
+	inputBroadcast   = "broadcast"    // текст рассылки администратора
+	inputImport      = "import"       // файл из /export_all
+	inputDoctorRange = "doctor_range" // свой период отчёта для врача
+	inputBulk        = "bulk"         // список добавок для /bulk
+)
+
+// Ожидаемый от пользователя текстовый ввод
+type PendingInput struct {
+	Kind         string
+	SupplementID uuid.UUID
+	IntakeTime   string
+	Date         time.Time
+	Source       string
+}
+
+var inputStates = struct {
+	sync.RWMutex
+	m map[int64]*PendingInput
+}{m: make(map[int64]*PendingInput)}
+
+func setPendingInput(userID int64, input *PendingInput) {
+	inputStates.Lock()
+	inputStates.m[userID] = input
+	inputStates.Unlock()
+}
+
+// Забирает ожидаемый ввод пользователя, если он есть
+func takePendingInput(userID int64) (*PendingInput, bool) {
+	inputStates.Lock()
+	defer inputStates.Unlock()
+	input, ok := inputStates.m[userID]
+	if ok {
+		delete(inputStates.m, userID)
+	}
+	return input, ok
+}
+
+// Общий обработчик текста: сначала ожидаемый ввод, затем мастер добавления
+func TextHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	addText := AddTextHandler(b, log)
+	return func(c tele.Context) error {
+		text := c.Text()
+		input, ok := takePendingInput(c.Sender().ID)
+		if ok && text == "❌ Отмена" {
+			return c.Send("Отменено.", utils.MainMenuKeyboard())
+		}
+		if ok && (len(text) == 0 || text[0] != '/') {
+			switch input.Kind {
+			case inputTakenAt:
+				return handleTakenAtInput(c, log, input)
+			case inputNote:
+				return handleNoteInput(c, log, input)
+			case inputStatsRange:
+				return handleStatsRangeInput(c, log)
+			case inputReportTime, inputTimezone:
+				return handleReportInput(c, log, input)
+			case inputDoctorRange:
+				return handleDoctorRangeInput(c, log)
+			case inputBroadcast:
+				return handleBroadcastInput(c, log)
+			case inputBulk:
+				return handleBulkText(c, log, input)
+			}
+		}
+		return addText(c)
+	}
+}
+
+// Присланные файлы: принимаются, только если бот их ждёт
+func DocumentHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		input, ok := takePendingInput(c.Sender().ID)
+		if !ok {
+			return c.Send("Не знаю, что делать с этим файлом. Чтобы загрузить выгрузку, сначала отправь /import, а чтобы добавить добавки из CSV — /bulk")
+		}
+		switch input.Kind {
+		case inputImport:
+			return handleImportDocument(c, b, log, input)
+		case inputBulk:
+			return handleBulkDocument(c, b, log, input)
+		}
+		// Файл не подходит к ожидаемому вводу — продолжаем ждать текст
+		setPendingInput(c.Sender().ID, input)
+		return c.Send("Сейчас я жду текст, а не файл.")
+	}
+}
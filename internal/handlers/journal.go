@@ -0,0 +1,413 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/callback"
+	"DailyDoseBot/internal/db"
+	"DailyDoseBot/internal/intake"
+	"DailyDoseBot/internal/models"
+	"DailyDoseBot/internal/utils"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// Предопределённые побочные эффекты; в кнопках передаётся индекс, в базе — код
+var sideEffectTags = []struct {
+	Code  string
+	Label string
+}{
+	{"nausea", "Тошнота"},
+	{"headache", "Головная боль"},
+	{"heartburn", "Изжога"},
+	{"stomach", "Дискомфорт в животе"},
+	{"drowsy", "Сонливость"},
+	{"insomnia", "Бессонница"},
+	{"rash", "Сыпь"},
+	{"dizzy", "Головокружение"},
+}
+
+// Максимальная длина заметки
+const maxNoteLength = 500
+
+// Сколько последних записей показывать в /journal и сколько байт текста в сообщении
+const (
+	journalLimit   = 30
+	maxJournalText = 3500
+)
+
+func sideEffectLabel(code string) string {
+	for _, t := range sideEffectTags {
+		if t.Code == code {
+			return t.Label
+		}
+	}
+	return code
+}
+
+func decodeSideEffects(entry models.IntakeLog) []string {
+	var codes []string
+	if len(entry.SideEffects) > 2 {
+		_ = json.Unmarshal([]byte(entry.SideEffects), &codes)
+	}
+	return codes
+}
+
+// Находит запись о приёме или создаёт пустую, чтобы к ней можно было прикрепить заметку
+func findOrCreateIntakeLog(user models.User, supplement models.Supplement, day time.Time, intakeTime string) (models.IntakeLog, error) {
+	return intake.Ensure(intake.Dose{UserID: user.ID, SupplementID: supplement.ID, Date: day, Time: intakeTime})
+}
+
+// Сообщение с заметкой и переключателями побочных эффектов
+func noteMenu(supplement models.Supplement, entry models.IntakeLog) (string, *tele.ReplyMarkup) {
+	selected := make(map[string]bool)
+	for _, code := range decodeSideEffects(entry) {
+		selected[code] = true
+	}
+	id := callback.ID(supplement.ID)
+	date := entry.IntakeDate.Format(logDateFormat)
+	markup := &tele.ReplyMarkup{}
+	var rows []tele.Row
+	var row []tele.Btn
+	for i, t := range sideEffectTags {
+		label := "▫️ " + t.Label
+		if selected[t.Code] {
+			label = "✅ " + t.Label
+		}
+		row = append(row, callback.Button(markup, label, "note_tag", id, entry.IntakeTime, date, strconv.Itoa(i)))
+		if len(row) == 2 {
+			rows = append(rows, markup.Row(row...))
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, markup.Row(row...))
+	}
+	rows = append(rows, markup.Row(
+		callback.Button(markup, "✏️ Написать заметку", "note_text", id, entry.IntakeTime, date),
+		callback.Button(markup, "Готово", "note_done"),
+	))
+	markup.Inline(rows...)
+
+	note := entry.Note
+	if note == "" {
+		note = "—"
+	}
+	msg := fmt.Sprintf("📝 %s — %s\n\nЗаметка: %s\n\nОтметь побочные эффекты или напиши заметку:", doseLabel(supplement.Name, entry.IntakeTime), utils.FormatDateRu(entry.IntakeDate), note)
+	return msg, markup
+}
+
+// Разбирает общие для кнопок заметок поля: ID добавки, время, дата
+func noteTarget(c tele.Context, log *zap.Logger, fields []string) (models.User, models.Supplement, time.Time, error) {
+	var user models.User
+	var supplement models.Supplement
+	suppUUID, err := callback.ParseID(fields[0])
+	if err != nil {
+		return user, supplement, time.Time{}, err
+	}
+	day, err := parseLogDay(fields[2])
+	if err != nil {
+		return user, supplement, time.Time{}, err
+	}
+	if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+		return user, supplement, time.Time{}, err
+	}
+	supplement, err = ownedSupplement(log, user, suppUUID)
+	return user, supplement, day, err
+}
+
+// Callback-хендлер кнопки "Заметка": открывает меню заметки к приёму
+func HandleNoteCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		fields, err := callback.Decode(c.Callback(), 3) // ID добавки, время, дата
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		user, supplement, day, err := noteTarget(c, log, fields)
+		if err != nil {
+			return respondSupplementError(c, err)
+		}
+		entry, err := findOrCreateIntakeLog(user, supplement, day, fields[1])
+		if err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Ошибка сохранения"})
+		}
+		msg, markup := noteMenu(supplement, entry)
+		_ = c.Edit(msg, markup)
+		return c.Respond()
+	}
+}
+
+// Callback-хендлер переключения побочного эффекта
+func HandleNoteTagCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		fields, err := callback.Decode(c.Callback(), 4) // ID добавки, время, дата, индекс эффекта
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		idx, err := strconv.Atoi(fields[3])
+		if err != nil || idx < 0 || idx >= len(sideEffectTags) {
+			return respondBadCallback(c, log, callback.ErrMalformed)
+		}
+		user, supplement, day, err := noteTarget(c, log, fields)
+		if err != nil {
+			return respondSupplementError(c, err)
+		}
+		entry, err := findOrCreateIntakeLog(user, supplement, day, fields[1])
+		if err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Ошибка сохранения"})
+		}
+		code := sideEffectTags[idx].Code
+		codes := decodeSideEffects(entry)
+		found := false
+		for i, existing := range codes {
+			if existing == code {
+				codes = append(codes[:i], codes[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			codes = append(codes, code)
+		}
+		data, _ := json.Marshal(codes)
+		entry.SideEffects = data
+		if err := db.DB.Model(&entry).Update("side_effects", entry.SideEffects).Error; err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Ошибка сохранения"})
+		}
+		msg, markup := noteMenu(supplement, entry)
+		_ = c.Edit(msg, markup)
+		return c.Respond()
+	}
+}
+
+// Callback-хендлер "Написать заметку": ждёт текст от пользователя
+func HandleNoteTextCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		fields, err := callback.Decode(c.Callback(), 3) // ID добавки, время, дата
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		_, supplement, day, err := noteTarget(c, log, fields)
+		if err != nil {
+			return respondSupplementError(c, err)
+		}
+		setPendingInput(c.Sender().ID, &PendingInput{
+			Kind:         inputNote,
+			SupplementID: supplement.ID,
+			IntakeTime:   fields[1],
+			Date:         day,
+		})
+		_ = c.Respond()
+		return c.Send("✏️ Напиши заметку к приёму. Например: «тошнота после железа» или «принял половину».", utils.CancelKeyboard())
+	}
+}
+
+// Callback-хендлер "Готово" в меню заметки
+func HandleNoteDoneCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		if _, err := callback.Decode(c.Callback(), 0); err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		_ = c.Edit("📝 Заметка сохранена. Все заметки — в /journal", &tele.ReplyMarkup{})
+		return c.Respond()
+	}
+}
+
+// Сохраняет введённый текст заметки
+func handleNoteInput(c tele.Context, log *zap.Logger, input *PendingInput) error {
+	note := strings.TrimSpace(c.Text())
+	if note == "" {
+		setPendingInput(c.Sender().ID, input)
+		return c.Send("Заметка пустая, напиши текст.", utils.CancelKeyboard())
+	}
+	if r := []rune(note); len(r) > maxNoteLength {
+		note = string(r[:maxNoteLength])
+	}
+	var user models.User
+	if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+		return c.Send("Пользователь не найден.")
+	}
+	supplement, err := ownedSupplement(log, user, input.SupplementID)
+	if err != nil {
+		return c.Send("Добавка не найдена.")
+	}
+	entry, err := findOrCreateIntakeLog(user, supplement, input.Date, input.IntakeTime)
+	if err != nil {
+		return c.Send("Ошибка при сохранении заметки.")
+	}
+	if err := db.DB.Model(&entry).Update("note", note).Error; err != nil {
+		return c.Send("Ошибка при сохранении заметки.")
+	}
+	return c.Send("📝 Заметка сохранена: "+note, utils.MainMenuKeyboard())
+}
+
+// Самые частые побочные эффекты по добавке, например "Тошнота (3), Изжога (1)"
+func sideEffectSummary(supplementID uuid.UUID, limit int) string {
+	var entries []models.IntakeLog
+	if err := db.DB.Select("side_effects").Where("supplement_id = ? AND side_effects IS NOT NULL", supplementID).Find(&entries).Error; err != nil {
+		return ""
+	}
+	counts := make(map[string]int)
+	for _, e := range entries {
+		for _, code := range decodeSideEffects(e) {
+			counts[code]++
+		}
+	}
+	codes := make([]string, 0, len(counts))
+	for code := range counts {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if counts[codes[i]] != counts[codes[j]] {
+			return counts[codes[i]] > counts[codes[j]]
+		}
+		return codes[i] < codes[j]
+	})
+	if len(codes) > limit {
+		codes = codes[:limit]
+	}
+	var parts []string
+	for _, code := range codes {
+		parts = append(parts, fmt.Sprintf("%s (%d)", sideEffectLabel(code), counts[code]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Строит сообщение журнала за период с фильтром по добавке (uuid.Nil — все добавки)
+func buildJournal(user models.User, days int, supplementID uuid.UUID) (string, *tele.ReplyMarkup, error) {
+	var supplements []models.Supplement
+	if err := db.DB.Where("user_id = ?", user.ID).Order("name").Find(&supplements).Error; err != nil {
+		return "", nil, err
+	}
+	names := make(map[uuid.UUID]string, len(supplements))
+	for _, s := range supplements {
+		names[s.ID] = s.Name
+	}
+
+	query := db.DB.Where("user_id = ? AND intake_date >= ? AND (note <> '' OR (side_effects IS NOT NULL AND side_effects::text NOT IN ('null', '[]')))",
+		user.ID, nowDate().AddDate(0, 0, -days))
+	if supplementID != uuid.Nil {
+		query = query.Where("supplement_id = ?", supplementID)
+	}
+	var entries []models.IntakeLog
+	if err := query.Order("intake_date DESC, intake_time DESC").Limit(journalLimit).Find(&entries).Error; err != nil {
+		return "", nil, err
+	}
+
+	var sb strings.Builder
+	title := "все добавки"
+	if supplementID != uuid.Nil {
+		title = names[supplementID]
+	}
+	sb.WriteString(fmt.Sprintf("📓 Журнал за %d дней — %s\n\n", days, title))
+	if len(entries) == 0 {
+		sb.WriteString("Записей пока нет. Заметку можно добавить из напоминания или /log.")
+	}
+	for _, e := range entries {
+		// Telegram ограничивает длину сообщения, старые записи отбрасываем
+		if sb.Len() > maxJournalText {
+			sb.WriteString("…")
+			break
+		}
+		sb.WriteString(fmt.Sprintf("%s · %s\n", utils.FormatDateRu(e.IntakeDate), doseLabel(names[e.SupplementID], e.IntakeTime)))
+		if codes := decodeSideEffects(e); len(codes) > 0 {
+			var labels []string
+			for _, code := range codes {
+				labels = append(labels, sideEffectLabel(code))
+			}
+			sb.WriteString("🏷 " + strings.Join(labels, ", ") + "\n")
+		}
+		if e.Note != "" {
+			sb.WriteString("📝 " + e.Note + "\n")
+		}
+		sb.WriteString("\n")
+	}
+
+	// Фильтры: период и добавка
+	filter := "*"
+	if supplementID != uuid.Nil {
+		filter = callback.ID(supplementID)
+	}
+	markup := &tele.ReplyMarkup{}
+	var periodRow []tele.Btn
+	for _, p := range []int{7, 30, 90} {
+		label := fmt.Sprintf("%d дн.", p)
+		if p == days {
+			label = "• " + label
+		}
+		periodRow = append(periodRow, callback.Button(markup, label, "journal", strconv.Itoa(p), filter))
+	}
+	rows := []tele.Row{markup.Row(periodRow...)}
+	allLabel := "Все добавки"
+	if supplementID == uuid.Nil {
+		allLabel = "• " + allLabel
+	}
+	rows = append(rows, markup.Row(callback.Button(markup, allLabel, "journal", strconv.Itoa(days), "*")))
+	for _, s := range supplements {
+		label := s.Name
+		if s.ID == supplementID {
+			label = "• " + label
+		}
+		rows = append(rows, markup.Row(callback.Button(markup, label, "journal", strconv.Itoa(days), callback.ID(s.ID))))
+	}
+	markup.Inline(rows...)
+	return sb.String(), markup, nil
+}
+
+// /journal — заметки и побочные эффекты с фильтрами
+func JournalHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		var user models.User
+		if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+			return c.Send("Пользователь не найден.")
+		}
+		msg, markup, err := buildJournal(user, 30, uuid.Nil)
+		if err != nil {
+			log.Error("Ошибка получения журнала", zap.Error(err))
+			return c.Send("Ошибка при получении журнала.")
+		}
+		return c.Send(msg, markup)
+	}
+}
+
+// Callback-хендлер фильтров журнала
+func HandleJournalCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		fields, err := callback.Decode(c.Callback(), 2) // период, ID добавки или "*"
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		days, err := strconv.Atoi(fields[0])
+		if err != nil || days <= 0 || days > 365 {
+			return respondBadCallback(c, log, callback.ErrMalformed)
+		}
+		var user models.User
+		if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Пользователь не найден"})
+		}
+		supplementID := uuid.Nil
+		if fields[1] != "*" {
+			id, err := callback.ParseID(fields[1])
+			if err != nil {
+				return respondBadCallback(c, log, err)
+			}
+			supplement, err := ownedSupplement(log, user, id)
+			if err != nil {
+				return respondSupplementError(c, err)
+			}
+			supplementID = supplement.ID
+		}
+		msg, markup, err := buildJournal(user, days, supplementID)
+		if err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Ошибка при получении журнала"})
+		}
+		_ = c.Edit(msg, markup)
+		return c.Respond()
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"DailyDoseBot/internal/callback"
 	"DailyDoseBot/internal/db"
 	"DailyDoseBot/internal/models"
 	"DailyDoseBot/internal/utils"
@@ -34,25 +35,7 @@ func supplementInfoText(s models.Supplement) string {
 		intakeTime = "Любое время"
 	}
 
-	// Дни недели приёма
-	var daysOfWeek []int
-	var daysText string
-	if err := utils.UnmarshalJSON(s.DaysOfWeek, &daysOfWeek); err == nil && len(daysOfWeek) > 0 {
-		daysRu := []string{"Пн", "Вт", "Ср", "Чт", "Пт", "Сб", "Вс"}
-		if len(daysOfWeek) == 7 {
-			daysText = "Каждый день"
-		} else {
-			var names []string
-			for _, d := range daysOfWeek {
-				if d >= 0 && d < 7 {
-					names = append(names, daysRu[d])
-				}
-			}
-			daysText = "" + strings.Join(names, ", ")
-		}
-	} else {
-		daysText = "—"
-	}
+	daysText := daysOfWeekText(s)
 
 	// Время напоминания
 	reminder := "—"
@@ -65,44 +48,91 @@ func supplementInfoText(s models.Supplement) string {
 	} else if !s.ReminderEnabled {
 		reminder = "Отключены"
 	}
+	if s.IsPRN() {
+		intakeTime = prnScheduleText(s)
+		reminder = "Нет"
+	}
 
 	return fmt.Sprintf("Добавка: %s\nДозировка: %s\nВремя приёма: %s\nДни приёма: %s\nС едой: %v\nДата начала: %s\nДата окончания: %s\nНапоминания: %s",
 		s.Name, s.Dosage, intakeTime, daysText, withFood, utils.FormatDateRu(s.StartDate), endDate, reminder)
 }
+
+// Дни недели приёма: "Каждый день", "Пн, Ср, Пт" или "—"
+func daysOfWeekText(s models.Supplement) string {
+	var daysOfWeek []int
+	if err := utils.UnmarshalJSON(s.DaysOfWeek, &daysOfWeek); err != nil || len(daysOfWeek) == 0 {
+		return "—"
+	}
+	if len(daysOfWeek) == 7 {
+		return "Каждый день"
+	}
+	daysRu := []string{"Пн", "Вт", "Ср", "Чт", "Пт", "Сб", "Вс"}
+	var names []string
+	for _, d := range daysOfWeek {
+		if d >= 0 && d < 7 {
+			names = append(names, daysRu[d])
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
 func supplementDetailHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
 	return func(c tele.Context) error {
 		userID := c.Sender().ID
-		name := c.Data()
+		fields, err := callback.Decode(c.Callback(), 1)
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		suppID, err := callback.ParseID(fields[0])
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
 		var user models.User
 		if err := db.DB.First(&user, "telegram_id = ?", userID).Error; err != nil {
 			return c.Send("Пользователь не найден.")
 		}
-		var supplement models.Supplement
-		if err := db.DB.Where("user_id = ? AND name = ?", user.ID, name).First(&supplement).Error; err != nil {
-			return c.Send("Добавка не найдена.")
+		supplement, err := ownedSupplement(log, user, suppID)
+		if err != nil {
+			return respondSupplementError(c, err)
 		}
 		markup := &tele.ReplyMarkup{}
-		btnDelete := markup.Data("🗑 Удалить", "supplement_delete", supplement.Name)
+		btnDelete := callback.Button(markup, "🗑 Удалить", "supplement_delete", callback.ID(supplement.ID))
 		markup.Inline(markup.Row(btnDelete))
-		return c.Edit(supplementInfoText(supplement), markup)
+		text := supplementInfoText(supplement)
+		if effects := sideEffectSummary(supplement.ID, 3); effects != "" {
+			text += "\nЧастые побочные эффекты: " + effects
+		}
+		if !supplement.IsPRN() {
+			if a, err := supplementAdherence(supplement); err == nil {
+				text += "\n\n" + adherenceText(a)
+			}
+		}
+		return c.Edit(text, markup)
 	}
 }
 
 func supplementDeleteHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
 	return func(c tele.Context) error {
 		userID := c.Sender().ID
-		name := c.Data()
+		fields, err := callback.Decode(c.Callback(), 1)
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		suppID, err := callback.ParseID(fields[0])
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
 		var user models.User
 		if err := db.DB.First(&user, "telegram_id = ?", userID).Error; err != nil {
 			return c.Send("Пользователь не найден.")
 		}
-		var supplement models.Supplement
-		if err := db.DB.Where("user_id = ? AND name = ?", user.ID, name).First(&supplement).Error; err != nil {
-			return c.Send("Добавка не найдена.")
+		supplement, err := ownedSupplement(log, user, suppID)
+		if err != nil {
+			return respondSupplementError(c, err)
 		}
 		markup := &tele.ReplyMarkup{}
-		btnYes := markup.Data("✅ Да, удалить", "supplement_delete_confirm", supplement.Name)
-		btnNo := markup.Data("❌ Нет", "supplement_detail", supplement.Name)
+		btnYes := callback.Button(markup, "✅ Да, удалить", "supplement_delete_confirm", callback.ID(supplement.ID))
+		btnNo := callback.Button(markup, "❌ Нет", "supplement_detail", callback.ID(supplement.ID))
 		markup.Inline(markup.Row(btnYes, btnNo))
 		return c.Edit("Точно удалить добавку?", markup)
 	}
@@ -111,12 +141,24 @@ func supplementDeleteHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context)
 func supplementDeleteConfirmHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
 	return func(c tele.Context) error {
 		userID := c.Sender().ID
-		name := c.Data()
+		fields, err := callback.Decode(c.Callback(), 1)
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		suppID, err := callback.ParseID(fields[0])
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
 		var user models.User
 		if err := db.DB.First(&user, "telegram_id = ?", userID).Error; err != nil {
 			return c.Send("Пользователь не найден.")
 		}
-		if err := db.DB.Where("user_id = ? AND name = ?", user.ID, name).Delete(&models.Supplement{}).Error; err != nil {
+		supplement, err := ownedSupplement(log, user, suppID)
+		if err != nil {
+			return respondSupplementError(c, err)
+		}
+		// Удаляем строго одну запись по ID, а не все одноимённые добавки
+		if err := db.DB.Delete(&supplement).Error; err != nil {
 			return c.Send("Ошибка при удалении.")
 		}
 		return c.Edit("Добавка удалена ✅", &tele.ReplyMarkup{})
@@ -145,7 +187,7 @@ func createListInlineMarkup(supplements []models.Supplement) *tele.ReplyMarkup {
 	markup := &tele.ReplyMarkup{}
 	var rows []tele.Row
 	for _, s := range supplements {
-		btn := markup.Data(s.Name, "supplement_detail", s.Name)
+		btn := callback.Button(markup, s.Name, "supplement_detail", callback.ID(s.ID))
 		rows = append(rows, markup.Row(btn))
 	}
 	markup.Inline(rows...)
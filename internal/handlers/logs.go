@@ -1,18 +1,142 @@
 package handlers
 
 import (
+	"DailyDoseBot/internal/callback"
 	"DailyDoseBot/internal/db"
+	"DailyDoseBot/internal/intake"
 	"DailyDoseBot/internal/models"
-	"encoding/json"
+	"DailyDoseBot/internal/schedule"
+	"DailyDoseBot/internal/utils"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	"go.uber.org/zap"
 	tele "gopkg.in/telebot.v4"
 )
 
+// Формат даты в данных кнопок /log
+const logDateFormat = "20060102"
+
+// Статусы приёма в данных кнопок /log
+const (
+	intakeStatusTaken   = "t"
+	intakeStatusSkipped = "s"
+	intakeStatusMissed  = "m"
+	intakeStatusUndo    = "u"
+	intakeStatusPartial = "p"
+)
+
+var errLogDayOutOfRange = errors.New("дата вне допустимого диапазона")
+
+// Подпись приёма: название и время, если оно задано
+func doseLabel(name, intakeTime string) string {
+	if intakeTime == "" {
+		return name
+	}
+	return fmt.Sprintf("%s (%s)", name, intakeTime)
+}
+
+// Значок статуса приёма
+func intakeStatusIcon(entry *models.IntakeLog) string {
+	switch {
+	case entry != nil && entry.IsPartial():
+		return "🌓"
+	case entry != nil && entry.Taken:
+		return "✅"
+	case entry != nil && entry.Skipped:
+		return "⏭"
+	default:
+		return "❌"
+	}
+}
+
+// Разбирает дату из кнопки /log и проверяет, что она не в будущем и не глубже допустимого
+func parseLogDay(s string) (time.Time, error) {
+	day, err := time.Parse(logDateFormat, s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	today := nowDate()
+	if day.After(today) || day.Before(today.AddDate(0, 0, -appConfig.LogLookback)) {
+		return time.Time{}, errLogDayOutOfRange
+	}
+	return day, nil
+}
+
+// Строит сообщение /log за указанный день: приёмы с кнопками и навигация по дням
+func buildLogDay(user models.User, day time.Time) (string, *tele.ReplyMarkup, error) {
+	var supplements []models.Supplement
+	if err := db.DB.Where("user_id = ?", user.ID).Find(&supplements).Error; err != nil {
+		return "", nil, err
+	}
+	markup := &tele.ReplyMarkup{}
+	if len(supplements) == 0 {
+		return "У тебя пока нет добавок.", markup, nil
+	}
+
+	// Все отметки за день одним запросом
+	logs, err := loadIntakeLogs(user, day, day)
+	if err != nil {
+		return "", nil, err
+	}
+	today := nowDate()
+	dateStr := day.Format(logDateFormat)
+	var rows []tele.Row
+	doses := 0
+	for _, s := range supplements {
+		for _, occ := range schedule.Expand(s, day, day) {
+			t := occ.Time
+			var entry *models.IntakeLog
+			if logEntry, ok := logs[doseKey(s.ID, day, t)]; ok {
+				entry = &logEntry
+			}
+			doses++
+			label := intakeStatusIcon(entry) + " " + doseLabel(s.Name, t)
+			rows = append(rows, markup.Row(callback.Button(markup, label, "log_dose", callback.ID(s.ID), t, dateStr)))
+		}
+	}
+
+	// Добавки по необходимости можно отметить сколько угодно раз, но только за сегодня
+	if day.Equal(today) {
+		for _, s := range supplements {
+			if !s.IsPRN() || s.StartDate.After(day) || (s.EndDate != nil && s.EndDate.Before(day)) {
+				continue
+			}
+			doses++
+			rows = append(rows, markup.Row(callback.Button(markup, prnButtonLabel(s, prnTakenCount(logs, s.ID)), "prn_take", callback.ID(s.ID))))
+		}
+	}
+
+	// Навигация: назад — в пределах допустимой глубины, вперёд — не дальше сегодняшнего дня
+	var nav []tele.Btn
+	if prev := day.AddDate(0, 0, -1); !prev.Before(today.AddDate(0, 0, -appConfig.LogLookback)) {
+		nav = append(nav, callback.Button(markup, "◀️ "+prev.Format("02.01"), "log_day", prev.Format(logDateFormat)))
+	}
+	if day.Before(today) {
+		next := day.AddDate(0, 0, 1)
+		nav = append(nav, callback.Button(markup, next.Format("02.01")+" ▶️", "log_day", next.Format(logDateFormat)))
+		nav = append(nav, callback.Button(markup, "📅 Сегодня", "log_day", today.Format(logDateFormat)))
+	}
+	if len(nav) > 0 {
+		rows = append(rows, markup.Row(nav...))
+	}
+	markup.Inline(rows...)
+
+	title := "📊 Добавки на сегодня:"
+	if !day.Equal(today) {
+		title = "📊 Добавки на " + utils.FormatDateRu(day) + ":"
+	}
+	if doses == 0 {
+		title += "\n\nВ этот день приёмов не запланировано."
+	} else {
+		title += "\n\nНажми на приём, чтобы изменить отметку."
+	}
+	return title, markup, nil
+}
+
 // Лог-хендлер: показывает список добавок на сегодня с кнопками для ручной отметки
 func LogHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
 	return func(c tele.Context) error {
@@ -22,104 +146,138 @@ func LogHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
 		if err := db.DB.First(&user, "telegram_id = ?", userID).Error; err != nil {
 			return c.Send("Пользователь не найден.")
 		}
-		// Получаем все добавки пользователя
-		var supplements []models.Supplement
-		if err := db.DB.Where("user_id = ?", user.ID).Find(&supplements).Error; err != nil {
+		text, markup, err := buildLogDay(user, nowDate())
+		if err != nil {
 			return c.Send("Ошибка при получении добавок.")
 		}
-		if len(supplements) == 0 {
-			return c.Send("У тебя пока нет добавок.")
+		return c.Send(text, markup)
+	}
+}
+
+// Callback-хендлер навигации по дням в /log
+func HandleLogDayCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		fields, err := callback.Decode(c.Callback(), 1) // дата
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		day, err := parseLogDay(fields[0])
+		if err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Этот день недоступен"})
+		}
+		var user models.User
+		if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Пользователь не найден"})
+		}
+		text, markup, err := buildLogDay(user, day)
+		if err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Ошибка при получении добавок"})
+		}
+		_ = c.Edit(text, markup)
+		return c.Respond()
+	}
+}
+
+// Callback-хендлер выбора приёма в /log: предлагает отметить статус
+func HandleLogDoseCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		fields, err := callback.Decode(c.Callback(), 3) // ID добавки, время, дата
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		suppUUID, err := callback.ParseID(fields[0])
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		intakeTime := fields[1]
+		day, err := parseLogDay(fields[2])
+		if err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Этот день недоступен"})
+		}
+		var user models.User
+		if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Пользователь не найден"})
+		}
+		supplement, err := ownedSupplement(log, user, suppUUID)
+		if err != nil {
+			return respondSupplementError(c, err)
+		}
+		var entry *models.IntakeLog
+		if logEntry, ok, _ := intake.Find(intake.Dose{UserID: user.ID, SupplementID: supplement.ID, Date: day, Time: intakeTime}); ok {
+			entry = &logEntry
+		}
+		current := "не принято"
+		if entry != nil && entry.IsPartial() {
+			current = "частично, " + partialText(*entry)
+		} else if entry != nil && entry.Taken {
+			current = "принято"
+		} else if entry != nil && entry.Skipped {
+			current = "пропущено"
 		}
 
-		today := time.Now().Truncate(24 * time.Hour)
+		id := callback.ID(supplement.ID)
 		markup := &tele.ReplyMarkup{}
-		var rows []tele.Row
-		for _, s := range supplements {
-			// Проверяем, нужно ли принимать сегодня
-			addToday := true
-			if len(s.DaysOfWeek) > 2 {
-				var daysOfWeek []int
-				_ = json.Unmarshal([]byte(s.DaysOfWeek), &daysOfWeek)
-				todayWeekday := int(today.Weekday())
-				if todayWeekday == 0 {
-					todayWeekday = 6 // Go: Sunday=0, а у нас Вс=6
-				} else {
-					todayWeekday-- // Go: Monday=1, а у нас Пн=0
-				}
-				found := false
-				for _, d := range daysOfWeek {
-					if d == todayWeekday {
-						found = true
-						break
-					}
-				}
-				if !found {
-					addToday = false
-				}
-			}
-			if !addToday {
-				continue
-			}
-			// Получаем список времён напоминаний
-			var times []string
-			if s.ReminderEnabled && len(s.ReminderTimes) > 2 {
-				_ = db.DB.Statement.Context // для совместимости
-				_ = json.Unmarshal([]byte(s.ReminderTimes), &times)
-			}
-			if len(times) == 0 {
-				// Если нет времён — просто как раньше
-				var logEntry models.IntakeLog
-				err := db.DB.Where("user_id = ? AND supplement_id = ? AND intake_date = ?", user.ID, s.ID, today).First(&logEntry).Error
-				if err == nil && logEntry.Taken {
-					row := markup.Row(markup.Text(fmt.Sprintf("✅ %s — принято сегодня", s.Name)))
-					rows = append(rows, row)
-				} else {
-					btn := markup.Data(fmt.Sprintf("❌ %s — ещё не принято", s.Name), "intake_accept_log", s.ID.String()+"|")
-					row := markup.Row(btn)
-					rows = append(rows, row)
-				}
-				continue
-			}
-			// Для каждой добавки с несколькими временами
-			allTaken := true
-			for _, t := range times {
-				var logEntry models.IntakeLog
-				err := db.DB.Where("user_id = ? AND supplement_id = ? AND intake_date = ? AND intake_time = ?", user.ID, s.ID, today, t).First(&logEntry).Error
-				if err == nil && logEntry.Taken {
-					row := markup.Row(markup.Text(fmt.Sprintf("✅ %s (%s)", s.Name, t)))
-					rows = append(rows, row)
-				} else {
-					allTaken = false
-					btn := markup.Data(fmt.Sprintf("❌ %s (%s)", s.Name, t), "intake_accept_log", s.ID.String()+"|"+t)
-					row := markup.Row(btn)
-					rows = append(rows, row)
-				}
-			}
-			if allTaken && len(times) > 1 {
-				// Если все времена отмечены, добавить итоговую строку
-				row := markup.Row(markup.Text(fmt.Sprintf("✅ %s — все приёмы за сегодня выполнены", s.Name)))
-				rows = append(rows, row)
-			}
+		rows := []tele.Row{
+			markup.Row(
+				callback.Button(markup, "✅ Принял(а)", "log_set", id, intakeTime, fields[2], intakeStatusTaken),
+				callback.Button(markup, "⏭ Пропустил(а)", "log_set", id, intakeTime, fields[2], intakeStatusSkipped),
+			),
+			markup.Row(
+				callback.Button(markup, "❌ Не принял(а)", "log_set", id, intakeTime, fields[2], intakeStatusMissed),
+				callback.Button(markup, "🕒 В другое время", "taken_at", id, intakeTime, fields[2], takenAtFromLog),
+			),
+			markup.Row(
+				callback.Button(markup, "📝 Заметка", "note", id, intakeTime, fields[2]),
+				callback.Button(markup, "◀️ Назад", "log_day", fields[2]),
+			),
+		}
+		planned := plannedUnits(supplement.Dosage)
+		var partial []tele.Btn
+		for _, k := range partialOptions(planned) {
+			partial = append(partial, callback.Button(markup, partialLabel(k, planned), "log_set", id, intakeTime, fields[2], intakeStatusPartial+strconv.Itoa(k)))
+		}
+		if len(partial) > 0 {
+			rows = append(rows, markup.Row(partial...))
+		}
+		if entry != nil && (entry.Taken || entry.Skipped) {
+			rows = append(rows, markup.Row(callback.Button(markup, "↩️ Отменить отметку", "log_set", id, intakeTime, fields[2], intakeStatusUndo)))
 		}
 		markup.Inline(rows...)
-		return c.Send("📊 Добавки на сегодня:", markup)
+		msg := fmt.Sprintf("%s — %s\nСейчас: %s %s\n\nЧто отметить?", doseLabel(supplement.Name, intakeTime), utils.FormatDateRu(day), intakeStatusIcon(entry), current)
+		_ = c.Edit(msg, markup)
+		return c.Respond()
 	}
 }
 
-// Callback-хендлер для ручной отметки приёма из /log
+// Callback-хендлер для ручной отметки приёма из /log, в том числе задним числом
 func HandleIntakeAcceptLogCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
 	return func(c tele.Context) error {
 		userID := c.Sender().ID
-		data := c.Data() // s.ID|time
-		parts := strings.SplitN(data, "|", 2)
-		suppIDStr := parts[0]
-		intakeTime := ""
-		if len(parts) > 1 {
-			intakeTime = parts[1]
-		}
-		suppUUID, err := uuid.Parse(suppIDStr)
+		fields, err := callback.Decode(c.Callback(), 4) // ID добавки, время, дата, статус
 		if err != nil {
-			return c.Respond(&tele.CallbackResponse{Text: "Ошибка ID"})
+			return respondBadCallback(c, log, err)
+		}
+		suppUUID, err := callback.ParseID(fields[0])
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		intakeTime := fields[1]
+		day, err := parseLogDay(fields[2])
+		if err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Этот день недоступен"})
+		}
+		status := fields[3]
+		// Частичный приём передаётся как "p<количество>"
+		quantity := 0
+		if strings.HasPrefix(status, intakeStatusPartial) {
+			quantity, err = strconv.Atoi(strings.TrimPrefix(status, intakeStatusPartial))
+			if err != nil || quantity <= 0 {
+				return respondBadCallback(c, log, callback.ErrMalformed)
+			}
+			status = intakeStatusPartial
+		}
+		if status != intakeStatusTaken && status != intakeStatusPartial && status != intakeStatusSkipped && status != intakeStatusMissed && status != intakeStatusUndo {
+			return respondBadCallback(c, log, callback.ErrMalformed)
 		}
 		// Получаем пользователя
 		var user models.User
@@ -127,31 +285,68 @@ func HandleIntakeAcceptLogCallback(b *tele.Bot, log *zap.Logger) func(c tele.Con
 			return c.Respond(&tele.CallbackResponse{Text: "Пользователь не найден"})
 		}
 		// Получаем добавку
-		var supplement models.Supplement
-		if err := db.DB.First(&supplement, "id = ?", suppUUID).Error; err != nil {
-			return c.Respond(&tele.CallbackResponse{Text: "Добавка не найдена"})
+		supplement, err := ownedSupplement(log, user, suppUUID)
+		if err != nil {
+			return respondSupplementError(c, err)
+		}
+		taken := status == intakeStatusTaken || status == intakeStatusPartial
+		var quantityTaken, quantityPlanned float64
+		if status == intakeStatusPartial {
+			units := plannedUnits(supplement.Dosage)
+			if quantity >= units {
+				return respondBadCallback(c, log, callback.ErrMalformed)
+			}
+			quantityTaken, quantityPlanned = float64(quantity), float64(units)
+		}
+		backfilled := day.Before(nowDate())
+		// Фактическое время известно, только если отмечают сегодняшний приём
+		var takenAt *time.Time
+		if taken && !backfilled {
+			now := time.Now()
+			takenAt = &now
 		}
-		today := time.Now().Truncate(24 * time.Hour)
-		// Проверяем, есть ли уже IntakeLog
+		dose := intake.Dose{UserID: user.ID, SupplementID: supplement.ID, Date: day, Time: intakeTime}
 		var logEntry models.IntakeLog
-		err = db.DB.Where("user_id = ? AND supplement_id = ? AND intake_date = ? AND intake_time = ?", user.ID, supplement.ID, today, intakeTime).First(&logEntry).Error
-		if err == nil {
-			// Уже есть запись, обновим
-			logEntry.Taken = true
-			db.DB.Save(&logEntry)
+		if status == intakeStatusUndo {
+			// Отмена отметки: снимаем статус, заметки остаются, история — в журнале аудита
+			removed := false
+			logEntry, removed, err = intake.Remove(dose)
+			if err != nil {
+				return c.Respond(&tele.CallbackResponse{Text: "Ошибка при отмене"})
+			}
+			if removed {
+				auditIntake(log, logEntry, auditActionUndo, auditSourceLog)
+			}
 		} else {
-			// Нет записи — создаём
-			logEntry = models.IntakeLog{
-				UserID:       user.ID,
-				SupplementID: supplement.ID,
-				IntakeDate:   today,
-				IntakeTime:   intakeTime,
-				Taken:        true,
+			logEntry, err = intake.Record(dose, intake.Status{
+				Taken:           taken,
+				Skipped:         status == intakeStatusSkipped,
+				Backfilled:      backfilled,
+				TakenAt:         takenAt,
+				QuantityTaken:   quantityTaken,
+				QuantityPlanned: quantityPlanned,
+			})
+			if err != nil {
+				log.Error("Ошибка сохранения приёма", zap.Error(err))
+				return c.Respond(&tele.CallbackResponse{Text: "Ошибка сохранения"})
 			}
-			db.DB.Create(&logEntry)
 		}
-		// Редактируем сообщение, убираем кнопку
-		_ = c.Edit("✅ Приём отмечен!", &tele.ReplyMarkup{})
-		return c.Respond(&tele.CallbackResponse{Text: "Отлично!"})
+		switch status {
+		case intakeStatusTaken:
+			auditIntake(log, logEntry, auditActionTaken, auditSourceLog)
+			announceAchievements(c, log, user)
+		case intakeStatusPartial:
+			auditIntake(log, logEntry, auditActionPartial, auditSourceLog)
+		case intakeStatusSkipped:
+			auditIntake(log, logEntry, auditActionSkipped, auditSourceLog)
+		case intakeStatusMissed:
+			auditIntake(log, logEntry, auditActionMissed, auditSourceLog)
+		}
+		// Возвращаемся к списку приёмов за этот день
+		text, markup, err := buildLogDay(user, day)
+		if err == nil {
+			_ = c.Edit(text, markup)
+		}
+		return c.Respond(&tele.CallbackResponse{Text: "Отмечено!"})
 	}
 }
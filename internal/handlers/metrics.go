@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+)
+
+// Окно, за которое считаются отправленные напоминания
+const metricsWindow = 24 * time.Hour
+
+// Результаты отправки напоминаний за последние сутки (в памяти, с момента запуска)
+var reminderMetrics = struct {
+	sync.Mutex
+	events  []reminderEvent
+	started time.Time
+}{started: time.Now()}
+
+type reminderEvent struct {
+	At     time.Time
+	Failed bool
+}
+
+// Запоминает результат отправки напоминания
+func recordReminder(err error) {
+	reminderMetrics.Lock()
+	defer reminderMetrics.Unlock()
+	now := time.Now()
+	reminderMetrics.events = append(pruneReminderEvents(now), reminderEvent{At: now, Failed: err != nil})
+}
+
+// Отбрасывает события старше окна; вызывать под блокировкой
+func pruneReminderEvents(now time.Time) []reminderEvent {
+	events := reminderMetrics.events
+	i := 0
+	for i < len(events) && now.Sub(events[i].At) > metricsWindow {
+		i++
+	}
+	return events[i:]
+}
+
+// Сколько напоминаний отправлено и не доставлено за последние сутки и с какого момента идёт учёт
+func reminderStats() (sent, failed int, since time.Time) {
+	reminderMetrics.Lock()
+	defer reminderMetrics.Unlock()
+	now := time.Now()
+	reminderMetrics.events = pruneReminderEvents(now)
+	for _, e := range reminderMetrics.events {
+		if e.Failed {
+			failed++
+		} else {
+			sent++
+		}
+	}
+	since = now.Add(-metricsWindow)
+	if reminderMetrics.started.After(since) {
+		since = reminderMetrics.started
+	}
+	return sent, failed, since
+}
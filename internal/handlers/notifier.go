@@ -1,11 +1,13 @@
 package handlers
 
 import (
+	"DailyDoseBot/internal/callback"
 	"DailyDoseBot/internal/db"
+	"DailyDoseBot/internal/intake"
 	"DailyDoseBot/internal/models"
-	"DailyDoseBot/internal/utils"
+	"DailyDoseBot/internal/schedule"
 	"fmt"
-	"strings"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,14 +24,24 @@ func StartNotifier(bot *tele.Bot, log *zap.Logger) {
 		log.Info("Запуск напоминания")
 		SendReminders(bot, time.Now())
 	})
-	c.AddFunc("0 7 * * 1", func() {
-		log.Info("Отправка еженедельной статистики")
-		SendWeeklyStats(bot, log)
+	// Отчёты уходят по расписанию каждого пользователя, проверяем каждые 5 минут
+	c.AddFunc("*/5 * * * *", func() {
+		SendDueReports(bot, log, time.Now())
+	})
+	// Заморозки серии тратятся раз в сутки, когда вчерашний день уже закрыт
+	c.AddFunc("5 0 * * *", func() {
+		SpendStreakFreezes(log, time.Now())
 	})
 
 	c.Start()
 }
 
+// Напоминание, которое пора отправить
+type dueReminder struct {
+	Supplement models.Supplement
+	Time       string
+}
+
 // Отправляет напоминания пользователям, у кого есть добавки на текущее время
 // и повторяет напоминание, если не отмечен приём
 func SendReminders(bot *tele.Bot, now time.Time) {
@@ -37,111 +49,167 @@ func SendReminders(bot *tele.Bot, now time.Time) {
 	if err := db.DB.Where("reminder_enabled = ?", true).Find(&supplements).Error; err != nil {
 		return
 	}
-	current := now.Format("15:04")
-	todayWeekday := int(now.Weekday())
-	if todayWeekday == 0 {
-		todayWeekday = 6 // Go: Sunday=0, а у нас Вс=6
-	} else {
-		todayWeekday-- // Go: Monday=1, а у нас Пн=0
+	for _, r := range dueReminders(supplements, now) {
+		// Получаем пользователя
+		var user models.User
+		if err := db.DB.First(&user, "id = ?", r.Supplement.UserID).Error; err != nil {
+			continue
+		}
+		msg, markup := reminderMessage(r.Supplement, r.Time, nowDate())
+		_, err := bot.Send(&tele.User{ID: int64(user.TelegramID)}, msg, markup)
+		recordReminder(err)
 	}
+}
+
+// Напоминания, которые нужно отправить в момент now: время совпало или приём просрочен и не отмечен
+func dueReminders(supplements []models.Supplement, now time.Time) []dueReminder {
+	current := now.Format("15:04")
+	today := schedule.Date(now)
+	var due []dueReminder
 	for _, s := range supplements {
-		// Проверяем, нужно ли принимать сегодня
-		var daysOfWeek []int
-		if err := utils.UnmarshalJSON(s.DaysOfWeek, &daysOfWeek); err == nil && len(daysOfWeek) > 0 {
-			found := false
-			for _, d := range daysOfWeek {
-				if d == todayWeekday {
-					found = true
-					break
-				}
+		// Расписание учитывает дни недели, даты курса и приём по необходимости
+		for _, occ := range schedule.Expand(s, today, today) {
+			t := occ.Time
+			if t == "" {
+				continue
 			}
-			if !found {
-				continue // Сегодня не нужно принимать
-			}
-		}
-		var times []string
-		_ = utils.UnmarshalJSON(s.ReminderTimes, &times)
-		for _, t := range times {
 			if t == current || isMissedReminder(s, t, now) {
-				// Получаем пользователя
-				var user models.User
-				if err := db.DB.First(&user, "id = ?", s.UserID).Error; err != nil {
-					continue
-				}
 				// Проверяем, был ли отмечен приём
-				taken := wasIntakeLogged(s, now, t)
-				if !taken {
-					msg := fmt.Sprintf("⏰ Напоминание! Не забудь принять: %s (%s) \nВы просили напомнить в %s", s.Name, s.Dosage, t)
-					markup := &tele.ReplyMarkup{}
-					btnAccept := markup.Data("✅ Принял(а)", "intake_accept", fmt.Sprintf("%s|%s", s.ID.String(), t))
-					markup.Inline(markup.Row(btnAccept))
-					_, _ = bot.Send(&tele.User{ID: int64(user.TelegramID)}, msg, markup)
+				if !wasIntakeLogged(s, now, t) {
+					due = append(due, dueReminder{Supplement: s, Time: t})
 				}
 			}
 		}
 	}
+	return due
+}
+
+// Текст напоминания и кнопки к нему
+func reminderMessage(s models.Supplement, t string, day time.Time) (string, *tele.ReplyMarkup) {
+	msg := fmt.Sprintf("⏰ Напоминание! Не забудь принять: %s (%s) \nВы просили напомнить в %s", s.Name, s.Dosage, t)
+	markup := &tele.ReplyMarkup{}
+	btnAccept := callback.Button(markup, "✅ Принял(а)", "intake_accept", callback.ID(s.ID), t)
+	btnOther := callback.Button(markup, "🕒 В другое время", "taken_at", callback.ID(s.ID), t, day.Format(logDateFormat), takenAtFromReminder)
+	rows := []tele.Row{markup.Row(btnAccept)}
+	var partial []tele.Btn
+	for _, k := range partialOptions(plannedUnits(s.Dosage)) {
+		partial = append(partial, callback.Button(markup, partialLabel(k, plannedUnits(s.Dosage)), "intake_part", callback.ID(s.ID), t, strconv.Itoa(k)))
+	}
+	if len(partial) > 0 {
+		rows = append(rows, markup.Row(partial...))
+	}
+	rows = append(rows, markup.Row(btnOther))
+	markup.Inline(rows...)
+	return msg, markup
 }
 
 // Callback-хендлер для кнопки "Принял(а)"
 func HandleIntakeAcceptCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
 	return func(c tele.Context) error {
-		userID := c.Sender().ID
-		data := c.Data() // s.ID|time
-		parts := strings.SplitN(data, "|", 2)
-		if len(parts) != 2 {
-			return c.Respond(&tele.CallbackResponse{Text: "Ошибка данных"})
+		fields, err := callback.Decode(c.Callback(), 2) // ID добавки, время
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		suppUUID, err := callback.ParseID(fields[0])
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		return acceptReminderIntake(c, log, suppUUID, fields[1], 0)
+	}
+}
+
+// Отмечает приём из напоминания; quantity > 0 — частичный приём в единицах дозы
+func acceptReminderIntake(c tele.Context, log *zap.Logger, suppUUID uuid.UUID, intakeTime string, quantity int) error {
+	// Получаем пользователя
+	var user models.User
+	if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "Пользователь не найден"})
+	}
+	// Логируем приём
+	today := nowDate()
+	supplement, err := ownedSupplement(log, user, suppUUID)
+	if err != nil {
+		return respondSupplementError(c, err)
+	}
+	var taken, planned float64
+	if quantity > 0 {
+		units := plannedUnits(supplement.Dosage)
+		if quantity >= units {
+			return respondBadCallback(c, log, callback.ErrMalformed)
+		}
+		taken, planned = float64(quantity), float64(units)
+	}
+	takenAt := time.Now()
+	logEntry, err := intake.Record(intake.Dose{UserID: user.ID, SupplementID: supplement.ID, Date: today, Time: intakeTime}, intake.Status{
+		Taken:           true,
+		TakenAt:         &takenAt,
+		QuantityTaken:   taken,
+		QuantityPlanned: planned,
+	})
+	if err != nil {
+		log.Error("Ошибка сохранения приёма", zap.Error(err))
+		return c.Respond(&tele.CallbackResponse{Text: "Ошибка сохранения"})
+	}
+	msg := "✅ Приём отмечен!"
+	if logEntry.IsPartial() {
+		auditIntake(log, logEntry, auditActionPartial, auditSourceReminder)
+		msg = fmt.Sprintf("🌓 Отмечен частичный приём: %s", partialText(logEntry))
+	} else {
+		auditIntake(log, logEntry, auditActionTaken, auditSourceReminder)
+		announceAchievements(c, log, user)
+	}
+	// Редактируем сообщение: вместо кнопки приёма — возможность отменить отметку
+	markup := &tele.ReplyMarkup{}
+	btnUndo := callback.Button(markup, "↩️ Отменить", "intake_undo", callback.ID(supplement.ID), intakeTime, today.Format(logDateFormat))
+	btnNote := callback.Button(markup, "📝 Заметка", "note", callback.ID(supplement.ID), intakeTime, today.Format(logDateFormat))
+	markup.Inline(markup.Row(btnUndo, btnNote))
+	_ = c.Edit(msg, markup)
+	return c.Respond(&tele.CallbackResponse{Text: "Отлично!"})
+}
+
+// Callback-хендлер для кнопки "Отменить" после отметки из напоминания:
+// удаляет отметку и возвращает напоминание с кнопкой приёма
+func HandleIntakeUndoCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		fields, err := callback.Decode(c.Callback(), 3) // ID добавки, время, дата
+		if err != nil {
+			return respondBadCallback(c, log, err)
 		}
-		suppIDStr := parts[0]
-		intakeTime := ""
-		if len(parts) > 1 {
-			intakeTime = parts[1]
+		suppUUID, err := callback.ParseID(fields[0])
+		if err != nil {
+			return respondBadCallback(c, log, err)
 		}
-		suppUUID, err := uuid.Parse(suppIDStr)
+		intakeTime := fields[1]
+		day, err := time.Parse(logDateFormat, fields[2])
 		if err != nil {
-			return c.Respond(&tele.CallbackResponse{Text: "Ошибка ID"})
+			return respondBadCallback(c, log, callback.ErrMalformed)
 		}
-		// Получаем пользователя
 		var user models.User
-		if err := db.DB.First(&user, "telegram_id = ?", userID).Error; err != nil {
+		if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
 			return c.Respond(&tele.CallbackResponse{Text: "Пользователь не найден"})
 		}
-		// Логируем приём
-		today := time.Now().Truncate(24 * time.Hour)
-		var supplement models.Supplement
-		if err := db.DB.First(&supplement, "id = ?", suppUUID).Error; err != nil {
-			return c.Respond(&tele.CallbackResponse{Text: "Добавка не найдена"})
+		supplement, err := ownedSupplement(log, user, suppUUID)
+		if err != nil {
+			return respondSupplementError(c, err)
 		}
-		// Проверяем, есть ли уже IntakeLog
-		var logEntry models.IntakeLog
-		err = db.DB.Where("user_id = ? AND supplement_id = ? AND intake_date = ? AND intake_time = ?", user.ID, supplement.ID, today, intakeTime).First(&logEntry).Error
-		if err == nil {
-			// Уже есть запись, обновим
-			logEntry.Taken = true
-			db.DB.Save(&logEntry)
-		} else {
-			// Нет записи — создаём
-			logEntry = models.IntakeLog{
-				UserID:       user.ID,
-				SupplementID: supplement.ID,
-				IntakeDate:   today,
-				IntakeTime:   intakeTime,
-				Taken:        true,
-			}
-			db.DB.Create(&logEntry)
+		logEntry, removed, err := intake.Remove(intake.Dose{UserID: user.ID, SupplementID: supplement.ID, Date: day, Time: intakeTime})
+		if err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Ошибка при отмене"})
+		}
+		if removed {
+			auditIntake(log, logEntry, auditActionUndo, auditSourceReminder)
 		}
-		// Редактируем сообщение, убираем кнопку
-		_ = c.Edit("✅ Приём отмечен!", &tele.ReplyMarkup{})
-		return c.Respond(&tele.CallbackResponse{Text: "Отлично!"})
+		msg, markup := reminderMessage(supplement, intakeTime, day)
+		_ = c.Edit(msg, markup)
+		return c.Respond(&tele.CallbackResponse{Text: "Отметка отменена"})
 	}
 }
 
-// Проверяет, был ли отмечен приём добавки в указанное время
+// Проверяет, был ли отмечен приём добавки в указанное время: каждая доза дня проверяется отдельно
 func wasIntakeLogged(s models.Supplement, now time.Time, reminderTime string) bool {
 	var log models.IntakeLog
-	date := now.Format("2006-01-02")
-	// Можно доработать: учитывать время, если нужно
-	err := db.DB.Where("supplement_id = ? AND intake_date = ?", s.ID, date).First(&log).Error
-	return err == nil && log.Taken
+	err := db.DB.Where("supplement_id = ? AND intake_date = ? AND intake_time = ?", s.ID, schedule.Date(now), reminderTime).First(&log).Error
+	return err == nil && (log.Taken || log.Skipped)
 }
 
 // Проверяет, нужно ли повторить напоминание, если время прошло, а приём не отмечен
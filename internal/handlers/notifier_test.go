@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/models"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// Между полуночью и смещением пояса сервера от UTC день берётся по местному календарю, а не по UTC
+func TestDueRemindersLocalMidnight(t *testing.T) {
+	useFakeDB(t) // отметок нет — все напоминания ещё не отмечены
+	msk := time.FixedZone("UTC+3", 3*60*60)
+	tuesday := time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)
+	monday := tuesday.AddDate(0, 0, -1)
+	supp := func(name, days string, start time.Time, end *time.Time) models.Supplement {
+		return models.Supplement{
+			ID: uuid.New(), Name: name, StartDate: start, EndDate: end,
+			DaysOfWeek: datatypes.JSON(days), ReminderEnabled: true, ReminderTimes: datatypes.JSON(`["00:30","02:30"]`),
+		}
+	}
+	supplements := []models.Supplement{
+		supp("по вторникам", `[1]`, monday, nil),
+		supp("по понедельникам", `[0]`, monday, nil),
+		supp("курс со вторника", `[]`, tuesday, nil),
+		supp("курс до понедельника", `[]`, monday.AddDate(0, 0, -7), &monday),
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want []string
+	}{
+		// Вторник 00:30 по Москве — ещё понедельник 21:30 по UTC
+		{"00:30 местного", time.Date(2026, 10, 20, 0, 30, 0, 0, msk), []string{"курс со вторника", "по вторникам"}},
+		{"02:30 местного", time.Date(2026, 10, 20, 2, 30, 0, 0, msk), []string{"курс со вторника", "курс со вторника", "по вторникам", "по вторникам"}},
+		// Понедельник 23:30 по Москве — последний день курса и понедельничное расписание
+		{"23:30 накануне", time.Date(2026, 10, 19, 23, 30, 0, 0, msk), []string{"курс до понедельника", "курс до понедельника", "по понедельникам", "по понедельникам"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, r := range dueReminders(supplements, tt.now) {
+				got = append(got, r.Supplement.Name)
+			}
+			sort.Strings(got)
+			if len(got) != len(tt.want) {
+				t.Fatalf("dueReminders = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("dueReminders = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/callback"
+	"DailyDoseBot/internal/models"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// Дозировка в штучных единицах: "2 капсулы утром", "10 капель", "3 таблетки"
+var dosageUnitsRegex = regexp.MustCompile(`(?i)^\s*(\d+)\s*(капсул|таблет|драже|капел|капл|шт|пакет|саше|ложк|порц|пастил|мармелад)`)
+
+// Максимум единиц, для которого имеет смысл предлагать частичный приём
+const maxPlannedUnits = 100
+
+// Количество единиц в разовой дозе; 0 — если дозировку нельзя разделить на штуки
+func plannedUnits(dosage string) int {
+	m := dosageUnitsRegex.FindStringSubmatch(dosage)
+	if m == nil {
+		return 0
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil || n < 2 || n > maxPlannedUnits {
+		return 0
+	}
+	return n
+}
+
+// Варианты частичного приёма для быстрых кнопок: все доли для малых доз,
+// четверть, половина и три четверти — для больших
+func partialOptions(planned int) []int {
+	if planned < 2 {
+		return nil
+	}
+	if planned <= 4 {
+		var options []int
+		for k := 1; k < planned; k++ {
+			options = append(options, k)
+		}
+		return options
+	}
+	var options []int
+	seen := make(map[int]bool)
+	for _, f := range []float64{0.25, 0.5, 0.75} {
+		k := int(math.Round(float64(planned) * f))
+		if k > 0 && k < planned && !seen[k] {
+			seen[k] = true
+			options = append(options, k)
+		}
+	}
+	return options
+}
+
+func partialLabel(k, planned int) string {
+	return fmt.Sprintf("🌓 %d из %d", k, planned)
+}
+
+// Текст частичного приёма: "1 из 2"
+func partialText(entry models.IntakeLog) string {
+	return fmt.Sprintf("%s из %s", strconv.FormatFloat(entry.QuantityTaken, 'f', -1, 64), strconv.FormatFloat(entry.QuantityPlanned, 'f', -1, 64))
+}
+
+// Callback-хендлер быстрых кнопок частичного приёма в напоминании
+func HandleIntakePartialCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		fields, err := callback.Decode(c.Callback(), 3) // ID добавки, время, количество
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		suppUUID, err := callback.ParseID(fields[0])
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		quantity, err := strconv.Atoi(fields[2])
+		if err != nil || quantity <= 0 {
+			return respondBadCallback(c, log, callback.ErrMalformed)
+		}
+		return acceptReminderIntake(c, log, suppUUID, fields[1], quantity)
+	}
+}
@@ -0,0 +1,391 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/callback"
+	"DailyDoseBot/internal/db"
+	"DailyDoseBot/internal/models"
+	"DailyDoseBot/internal/schedule"
+	"DailyDoseBot/internal/utils"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// Виды периодов /stats в данных кнопок
+const (
+	statsThisWeek = "w"
+	statsLastWeek = "l"
+	statsLast30   = "d"
+	statsMonth    = "m"
+	statsCustom   = "c"
+)
+
+// Самый длинный период, который можно запросить
+const maxStatsDays = 366
+
+var statsRangeRegex = regexp.MustCompile(`^\s*(\d{2}\.\d{2}\.\d{4})\s*[-–—]\s*(\d{2}\.\d{2}\.\d{4})\s*$`)
+
+var errStatsRange = errors.New("неверный период статистики")
+
+// Выполнение плана за день
+type dayProgress struct {
+	Date    time.Time
+	Planned int
+	Taken   int
+	Partial int
+	Done    float64 // принятая доля доз с учётом частичных приёмов
+}
+
+// Выполнение плана по одной добавке
+type supplementProgress struct {
+	Name    string
+	Planned int
+	Taken   float64
+}
+
+// Статистика пользователя за период
+type periodStats struct {
+	From, To    time.Time
+	Days        []dayProgress
+	Supplements []supplementProgress
+}
+
+// Ключ приёма в карте записей за период
+func doseKey(supplementID uuid.UUID, day time.Time, intakeTime string) string {
+	return supplementID.String() + "|" + day.UTC().Format(logDateFormat) + "|" + intakeTime
+}
+
+// Загружает записи о приёмах за период одним запросом
+func loadIntakeLogs(user models.User, from, to time.Time) (map[string]models.IntakeLog, error) {
+	var entries []models.IntakeLog
+	if err := db.DB.Where("user_id = ? AND intake_date BETWEEN ? AND ?", user.ID, from, to).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return indexIntakeLogs(entries), nil
+}
+
+// Раскладывает записи о приёмах по ключу дозы
+func indexIntakeLogs(entries []models.IntakeLog) map[string]models.IntakeLog {
+	logs := make(map[string]models.IntakeLog, len(entries))
+	for _, e := range entries {
+		logs[doseKey(e.SupplementID, e.IntakeDate, e.IntakeTime)] = e
+	}
+	return logs
+}
+
+// Считает выполнение плана за период включительно
+func buildPeriodStats(user models.User, from, to time.Time) (periodStats, error) {
+	var supplements []models.Supplement
+	if err := db.DB.Where("user_id = ?", user.ID).Order("name").Find(&supplements).Error; err != nil {
+		return periodStats{From: from, To: to}, err
+	}
+	logs, err := loadIntakeLogs(user, from, to)
+	if err != nil {
+		return periodStats{From: from, To: to}, err
+	}
+	return aggregatePeriodStats(supplements, logs, from, to), nil
+}
+
+// Выполнение плана по уже загруженным добавкам и записям, без обращения к базе
+func aggregatePeriodStats(supplements []models.Supplement, logs map[string]models.IntakeLog, from, to time.Time) periodStats {
+	stats := periodStats{From: from, To: to}
+	bySupplement := make([]supplementProgress, len(supplements))
+	for i, s := range supplements {
+		bySupplement[i].Name = s.Name
+	}
+	index := make(map[uuid.UUID]int, len(supplements))
+	for i, s := range supplements {
+		index[s.ID] = i
+	}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		stats.Days = append(stats.Days, dayProgress{Date: day})
+	}
+	for _, occ := range schedule.ExpandAll(supplements, from, to) {
+		progress := &stats.Days[int(occ.Date.Sub(from).Hours()/24)]
+		i := index[occ.SupplementID]
+		progress.Planned++
+		bySupplement[i].Planned++
+		entry, ok := logs[doseKey(occ.SupplementID, occ.Date, occ.Time)]
+		if !ok {
+			continue
+		}
+		bySupplement[i].Taken += entry.Portion()
+		progress.Done += entry.Portion()
+		if entry.IsPartial() {
+			progress.Partial++
+		} else if entry.Taken {
+			progress.Taken++
+		}
+	}
+	for _, s := range bySupplement {
+		if s.Planned > 0 {
+			stats.Supplements = append(stats.Supplements, s)
+		}
+	}
+	return stats
+}
+
+// Клетка прогресс-бара за день
+func dayProgressIcon(d dayProgress) string {
+	switch {
+	case d.Planned == 0:
+		return "⬜"
+	case d.Taken == d.Planned:
+		return "🟩"
+	case d.Taken > 0 || d.Partial > 0:
+		return "🟨"
+	default:
+		return "🟥"
+	}
+}
+
+// Процент без деления на ноль
+func percentOf(part float64, total int) int {
+	if total == 0 {
+		return 0
+	}
+	return int(part / float64(total) * 100)
+}
+
+// Начало недели (понедельник), в которую входит день
+func weekStart(day time.Time) time.Time {
+	return day.AddDate(0, 0, -schedule.Weekday(day))
+}
+
+// Границы периода по его виду относительно сегодняшнего дня
+func statsPeriod(kind string, today time.Time) (time.Time, time.Time) {
+	switch kind {
+	case statsLastWeek:
+		start := weekStart(today).AddDate(0, 0, -7)
+		return start, start.AddDate(0, 0, 6)
+	case statsLast30:
+		return today.AddDate(0, 0, -29), today
+	case statsMonth:
+		return today.AddDate(0, 0, 1-today.Day()), today
+	default:
+		return weekStart(today), today
+	}
+}
+
+// Сдвигает период на его длину назад (dir < 0) или вперёд (dir > 0)
+func shiftStatsPeriod(kind string, from, to time.Time, dir int) (time.Time, time.Time) {
+	switch kind {
+	case statsMonth:
+		start := from.AddDate(0, dir, 0)
+		return start, start.AddDate(0, 1, -1)
+	case statsThisWeek, statsLastWeek:
+		start := weekStart(from).AddDate(0, 0, 7*dir)
+		return start, start.AddDate(0, 0, 6)
+	default:
+		span := int(to.Sub(from).Hours()/24) + 1
+		return from.AddDate(0, 0, span*dir), to.AddDate(0, 0, span*dir)
+	}
+}
+
+// Проверяет границы периода: не пустой, не слишком длинный и не целиком в будущем
+func validStatsRange(from, to time.Time) bool {
+	if to.Before(from) || from.After(nowDate()) {
+		return false
+	}
+	return to.Sub(from) < maxStatsDays*24*time.Hour
+}
+
+// Кнопка перехода к периоду /stats
+func statsButton(markup *tele.ReplyMarkup, label, kind string, from, to time.Time) tele.Btn {
+	return callback.Button(markup, label, "stats", kind, from.Format(logDateFormat), to.Format(logDateFormat))
+}
+
+// Строит сообщение /stats за период с кнопками выбора и навигации
+func buildStatsPeriod(user models.User, kind string, from, to time.Time) (string, *tele.ReplyMarkup, error) {
+	today := nowDate()
+	// Будущие дни ещё не наступили, считаем только по сегодняшний
+	until := to
+	if until.After(today) {
+		until = today
+	}
+	stats, err := buildPeriodStats(user, from, until)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📈 Статистика с %s по %s\n\n", from.Format("02.01.2006"), to.Format("02.01.2006")))
+	planned, taken, partial, fullDays, activeDays := 0, 0, 0, 0, 0
+	for i, d := range stats.Days {
+		// По неделям: строка на каждые 7 дней
+		if i > 0 && schedule.Weekday(d.Date) == 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(dayProgressIcon(d))
+		planned += d.Planned
+		taken += d.Taken
+		partial += d.Partial
+		if d.Planned > 0 {
+			activeDays++
+			if d.Taken == d.Planned {
+				fullDays++
+			}
+		}
+	}
+	sb.WriteString("\n\n")
+	if planned == 0 {
+		sb.WriteString("За этот период приёмов не было запланировано.")
+	} else {
+		sb.WriteString(fmt.Sprintf("✅ Полностью выполнено: %d/%d дней (%d%%)\n", fullDays, activeDays, percentOf(float64(fullDays), activeDays)))
+		sb.WriteString(fmt.Sprintf("💊 Приёмов: %d/%d (%d%%)\n", taken, planned, percentOf(float64(taken), planned)))
+		if partial > 0 {
+			sb.WriteString(fmt.Sprintf("🌓 Частичных приёмов: %d\n", partial))
+		}
+		sb.WriteString("\nПо добавкам:\n")
+		for _, s := range stats.Supplements {
+			sb.WriteString(fmt.Sprintf("• %s — %d%% (%s/%d)\n", s.Name, percentOf(s.Taken, s.Planned), formatPortion(s.Taken), s.Planned))
+		}
+		sb.WriteString("\n🟩 – полностью 🟨 – частично 🟥 – не выполнено ⬜ – без приёмов")
+	}
+
+	markup := &tele.ReplyMarkup{}
+	periodBtn := func(label, k string) tele.Btn {
+		start, end := statsPeriod(k, today)
+		if k == kind && start.Equal(from) {
+			label = "• " + label
+		}
+		return statsButton(markup, label, k, start, end)
+	}
+	rows := []tele.Row{
+		markup.Row(periodBtn("Эта неделя", statsThisWeek), periodBtn("Прошлая неделя", statsLastWeek)),
+		markup.Row(periodBtn("30 дней", statsLast30), periodBtn("Этот месяц", statsMonth)),
+	}
+	var nav []tele.Btn
+	prevFrom, prevTo := shiftStatsPeriod(kind, from, to, -1)
+	nav = append(nav, statsButton(markup, "⬅️ Раньше", kind, prevFrom, prevTo))
+	if to.Before(today) {
+		nextFrom, nextTo := shiftStatsPeriod(kind, from, to, 1)
+		nav = append(nav, statsButton(markup, "Позже ➡️", kind, nextFrom, nextTo))
+	}
+	rows = append(rows, markup.Row(nav...))
+	rows = append(rows, markup.Row(
+		markup.Data("📅 Свой период", "stats_custom"),
+		callback.Button(markup, "🗓 Календарь", "stats_map", to.Format(heatmapMonthFormat)),
+	))
+	rows = append(rows, markup.Row(markup.Data("💊 По добавкам", "stats_supp")))
+	markup.Inline(rows...)
+	return sb.String(), markup, nil
+}
+
+// Количество приёмов: целое без дробной части
+func formatPortion(v float64) string {
+	if v == float64(int(v)) {
+		return fmt.Sprintf("%d", int(v))
+	}
+	return fmt.Sprintf("%.1f", v)
+}
+
+// /stats — статистика за выбранный период
+func StatsHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		var user models.User
+		if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+			return c.Send("Пользователь не найден.")
+		}
+		from, to := statsPeriod(statsThisWeek, nowDate())
+		msg, markup, err := buildStatsPeriod(user, statsThisWeek, from, to)
+		if err != nil {
+			log.Error("Ошибка получения статистики", zap.Error(err))
+			return c.Send("Ошибка при получении статистики.")
+		}
+		return c.Send(msg, markup)
+	}
+}
+
+// Callback-хендлер выбора периода и навигации /stats
+func HandleStatsCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		fields, err := callback.Decode(c.Callback(), 3) // вид периода, начало, конец
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		kind := fields[0]
+		switch kind {
+		case statsThisWeek, statsLastWeek, statsLast30, statsMonth, statsCustom:
+		default:
+			return respondBadCallback(c, log, callback.ErrMalformed)
+		}
+		from, errFrom := time.Parse(logDateFormat, fields[1])
+		to, errTo := time.Parse(logDateFormat, fields[2])
+		if errFrom != nil || errTo != nil {
+			return respondBadCallback(c, log, callback.ErrMalformed)
+		}
+		if !validStatsRange(from, to) {
+			return c.Respond(&tele.CallbackResponse{Text: "Этот период недоступен"})
+		}
+		var user models.User
+		if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Пользователь не найден"})
+		}
+		msg, markup, err := buildStatsPeriod(user, kind, from, to)
+		if err != nil {
+			log.Error("Ошибка получения статистики", zap.Error(err))
+			return c.Respond(&tele.CallbackResponse{Text: "Ошибка при получении статистики"})
+		}
+		_ = c.Edit(msg, markup)
+		return c.Respond()
+	}
+}
+
+// Callback-хендлер кнопки "Свой период": ждём ввод диапазона дат
+func HandleStatsCustomCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		setPendingInput(c.Sender().ID, &PendingInput{Kind: inputStatsRange})
+		_ = c.Respond()
+		return c.Send("Введи период в формате ДД.ММ.ГГГГ-ДД.ММ.ГГГГ, например 01.09.2026-15.09.2026", utils.CancelKeyboard())
+	}
+}
+
+// Разбирает введённый диапазон дат
+func parseStatsRange(text string) (time.Time, time.Time, error) {
+	m := statsRangeRegex.FindStringSubmatch(text)
+	if m == nil {
+		return time.Time{}, time.Time{}, errStatsRange
+	}
+	from, err := time.Parse("02.01.2006", m[1])
+	if err != nil {
+		return time.Time{}, time.Time{}, errStatsRange
+	}
+	to, err := time.Parse("02.01.2006", m[2])
+	if err != nil {
+		return time.Time{}, time.Time{}, errStatsRange
+	}
+	if !validStatsRange(from, to) {
+		return time.Time{}, time.Time{}, errStatsRange
+	}
+	return from, to, nil
+}
+
+// Обрабатывает введённый свой период /stats
+func handleStatsRangeInput(c tele.Context, log *zap.Logger) error {
+	from, to, err := parseStatsRange(c.Text())
+	if err != nil {
+		// Оставляем ожидание ввода, чтобы можно было исправить
+		setPendingInput(c.Sender().ID, &PendingInput{Kind: inputStatsRange})
+		return c.Send(fmt.Sprintf("Не понял период. Нужен формат ДД.ММ.ГГГГ-ДД.ММ.ГГГГ, не длиннее %d дней и не в будущем.", maxStatsDays), utils.CancelKeyboard())
+	}
+	var user models.User
+	if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+		return c.Send("Пользователь не найден.")
+	}
+	msg, markup, err := buildStatsPeriod(user, statsCustom, from, to)
+	if err != nil {
+		log.Error("Ошибка получения статистики", zap.Error(err))
+		return c.Send("Ошибка при получении статистики.", utils.MainMenuKeyboard())
+	}
+	if err := c.Send("Период принят ✅", utils.MainMenuKeyboard()); err != nil {
+		return err
+	}
+	return c.Send(msg, markup)
+}
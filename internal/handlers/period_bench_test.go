@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/models"
+	"DailyDoseBot/internal/schedule"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// Синтетический пользователь: много добавок с разными расписаниями и записи почти по всем дозам
+func syntheticData(supplementCount int, from, to time.Time) ([]models.Supplement, []models.IntakeLog) {
+	r := rand.New(rand.NewSource(1))
+	userID := uuid.New()
+	reminders := []string{`[]`, `["08:00"]`, `["08:00","20:00"]`, `["07:30","13:00","21:00"]`}
+	days := []string{`[0,1,2,3,4,5,6]`, `[0,2,4]`, `[5,6]`, `[]`}
+	supplements := make([]models.Supplement, supplementCount)
+	for i := range supplements {
+		supplements[i] = models.Supplement{
+			ID:              uuid.New(),
+			UserID:          userID,
+			Name:            fmt.Sprintf("Добавка %03d", i),
+			StartDate:       from.AddDate(0, 0, -r.Intn(30)),
+			DaysOfWeek:      datatypes.JSON(days[r.Intn(len(days))]),
+			ReminderTimes:   datatypes.JSON(reminders[r.Intn(len(reminders))]),
+			ReminderEnabled: r.Intn(4) > 0,
+			ScheduleType:    models.ScheduleRegular,
+		}
+		if r.Intn(10) == 0 {
+			supplements[i].ScheduleType = models.SchedulePRN
+		}
+	}
+	var entries []models.IntakeLog
+	for _, occ := range schedule.ExpandAll(supplements, from, to) {
+		switch n := r.Intn(10); {
+		case n < 7:
+			entries = append(entries, models.IntakeLog{SupplementID: occ.SupplementID, UserID: userID, IntakeDate: occ.Date, IntakeTime: occ.Time, Taken: true})
+		case n < 8:
+			entries = append(entries, models.IntakeLog{SupplementID: occ.SupplementID, UserID: userID, IntakeDate: occ.Date, IntakeTime: occ.Time,
+				Taken: true, QuantityTaken: 1, QuantityPlanned: 2})
+		case n < 9:
+			entries = append(entries, models.IntakeLog{SupplementID: occ.SupplementID, UserID: userID, IntakeDate: occ.Date, IntakeTime: occ.Time, Skipped: true})
+		}
+	}
+	return supplements, entries
+}
+
+// Статистика за год по 50 добавкам: весь расчёт идёт по записям одного запроса, без обращений к базе на дозу
+func BenchmarkAggregatePeriodStats(b *testing.B) {
+	to := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	from := to.AddDate(0, 0, 1-maxStatsDays)
+	supplements, entries := syntheticData(50, from, to)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		aggregatePeriodStats(supplements, indexIntakeLogs(entries), from, to)
+	}
+	b.ReportMetric(float64(len(entries)), "logs")
+}
+
+// Недельный отчёт с трендами за trendWeeks недель по 50 добавкам
+func BenchmarkAggregateWeeklyReport(b *testing.B) {
+	start := time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)
+	supplements, entries := syntheticData(50, start.AddDate(0, 0, -7*trendWeeks), start.AddDate(0, 0, 6))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		aggregateWeeklyReport(supplements, indexIntakeLogs(entries), start)
+	}
+	b.ReportMetric(float64(len(entries)), "logs")
+}
+
+// Раскладка записей по ключу дозы — то, что заменило запрос на каждую дозу
+func BenchmarkIndexIntakeLogs(b *testing.B) {
+	to := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	_, entries := syntheticData(50, to.AddDate(0, 0, 1-maxStatsDays), to)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		indexIntakeLogs(entries)
+	}
+	b.ReportMetric(float64(len(entries)), "logs")
+}
+
+// Агрегация должна сходиться с записями: все принятые дозы учтены
+func TestAggregatePeriodStatsSynthetic(t *testing.T) {
+	to := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	from := to.AddDate(0, 0, -29)
+	supplements, entries := syntheticData(20, from, to)
+	stats := aggregatePeriodStats(supplements, indexIntakeLogs(entries), from, to)
+	planned, done := 0, 0.0
+	for _, d := range stats.Days {
+		planned += d.Planned
+		done += d.Done
+	}
+	wantDone := 0.0
+	for _, e := range entries {
+		wantDone += e.Portion()
+	}
+	if want := len(schedule.ExpandAll(supplements, from, to)); planned != want {
+		t.Errorf("planned = %d, want %d", planned, want)
+	}
+	if done != wantDone {
+		t.Errorf("done = %v, want %v", done, wantDone)
+	}
+}
+
+// Число запросов к базе не зависит от количества добавок и дней: добавки и записи грузятся целиком
+func TestStatsQueryCount(t *testing.T) {
+	start := time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)
+	for _, count := range []int{1, 10, 50} {
+		supplements, entries := syntheticData(count, start.AddDate(0, 0, -7*trendWeeks), start.AddDate(0, 0, 6))
+		fake := useFakeDB(t, supplements, entries)
+		user := models.User{ID: supplements[0].UserID}
+
+		if _, err := buildPeriodStats(user, start.AddDate(0, 0, -7*trendWeeks), start.AddDate(0, 0, 6)); err != nil {
+			t.Fatal(err)
+		}
+		if fake.Queries != 2 {
+			t.Errorf("buildPeriodStats: %d добавок — %d запросов, want 2", count, fake.Queries)
+		}
+		fake.Queries = 0
+		if _, err := collectWeeklyReport(user, start); err != nil {
+			t.Fatal(err)
+		}
+		if fake.Queries != 2 {
+			t.Errorf("collectWeeklyReport: %d добавок — %d запросов, want 2", count, fake.Queries)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/callback"
+	"DailyDoseBot/internal/db"
+	"DailyDoseBot/internal/intake"
+	"DailyDoseBot/internal/models"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// Время записи приёма по необходимости: секунды делают каждую запись уникальной в пределах дня
+const prnTimeFormat = "15:04:05"
+
+// Описание расписания "по необходимости"
+func prnScheduleText(s models.Supplement) string {
+	if s.MaxDailyDoses > 0 {
+		return fmt.Sprintf("По необходимости, не более %d в день", s.MaxDailyDoses)
+	}
+	return "По необходимости"
+}
+
+// Сколько раз добавка по необходимости принята в указанный день
+func prnCount(supplement models.Supplement, day time.Time) int64 {
+	var count int64
+	db.DB.Model(&models.IntakeLog{}).Where("supplement_id = ? AND intake_date = ? AND taken = ?", supplement.ID, day, true).Count(&count)
+	return count
+}
+
+// Сколько раз добавка по необходимости принята по уже загруженным отметкам за день
+func prnTakenCount(logs map[string]models.IntakeLog, supplementID uuid.UUID) int64 {
+	var count int64
+	for _, e := range logs {
+		if e.SupplementID == supplementID && e.Taken {
+			count++
+		}
+	}
+	return count
+}
+
+// Подпись кнопки приёма по необходимости со счётчиком за сегодня
+func prnButtonLabel(supplement models.Supplement, count int64) string {
+	if supplement.MaxDailyDoses > 0 {
+		return fmt.Sprintf("➕ %s (сегодня %d/%d)", supplement.Name, count, supplement.MaxDailyDoses)
+	}
+	return fmt.Sprintf("➕ %s (сегодня %d)", supplement.Name, count)
+}
+
+// Записывает приём по необходимости и возвращает текст ответа;
+// exceeded — превышен ли дневной максимум
+func logPRNIntake(log *zap.Logger, user models.User, supplement models.Supplement) (string, bool, error) {
+	now := time.Now()
+	// Двойное нажатие в ту же секунду попадёт в ту же запись
+	entry, err := intake.Record(intake.Dose{UserID: user.ID, SupplementID: supplement.ID, Date: nowDate(), Time: now.Format(prnTimeFormat)}, intake.Status{
+		Taken:   true,
+		TakenAt: &now,
+	})
+	if err != nil {
+		return "", false, err
+	}
+	auditIntake(log, entry, auditActionTaken, auditSourceLog)
+
+	count := prnCount(supplement, entry.IntakeDate)
+	text := fmt.Sprintf("✅ %s — принято в %s (сегодня: %d)", supplement.Name, now.Format("15:04"), count)
+	if supplement.MaxDailyDoses > 0 && count > int64(supplement.MaxDailyDoses) {
+		return text + fmt.Sprintf("\n\n⚠️ Превышен дневной максимум: %d из %d. Будь осторожен!", count, supplement.MaxDailyDoses), true, nil
+	}
+	return text, false, nil
+}
+
+// Callback-хендлер кнопки приёма по необходимости (из /log и /took)
+func HandlePRNTakeCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		fields, err := callback.Decode(c.Callback(), 1) // ID добавки
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		suppUUID, err := callback.ParseID(fields[0])
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		var user models.User
+		if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Пользователь не найден"})
+		}
+		supplement, err := ownedSupplement(log, user, suppUUID)
+		if err != nil {
+			return respondSupplementError(c, err)
+		}
+		if !supplement.IsPRN() {
+			return c.Respond(&tele.CallbackResponse{Text: "Эта добавка принимается по расписанию"})
+		}
+		text, exceeded, err := logPRNIntake(log, user, supplement)
+		if err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Ошибка сохранения"})
+		}
+		// Обновляем счётчики на кнопках /log
+		if msgText, markup, err := buildLogDay(user, nowDate()); err == nil {
+			_ = c.Edit(msgText, markup)
+		}
+		announceAchievements(c, log, user)
+		if exceeded {
+			_ = c.Respond()
+			return c.Send(text)
+		}
+		return c.Respond(&tele.CallbackResponse{Text: text})
+	}
+}
+
+// /took [название] — быстро отметить приём добавки по необходимости
+func TookHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		var user models.User
+		if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+			return c.Send("Пользователь не найден.")
+		}
+		var supplements []models.Supplement
+		if err := db.DB.Where("user_id = ? AND schedule_type = ?", user.ID, models.SchedulePRN).Order("name").Find(&supplements).Error; err != nil {
+			return c.Send("Ошибка при получении добавок.")
+		}
+		if len(supplements) == 0 {
+			return c.Send("У тебя нет добавок «по необходимости». Добавь такую через /add и выбери «💊 По необходимости».")
+		}
+
+		query := strings.TrimSpace(c.Message().Payload)
+		if query != "" {
+			var matched []models.Supplement
+			for _, s := range supplements {
+				if strings.EqualFold(s.Name, query) {
+					matched = []models.Supplement{s}
+					break
+				}
+				if strings.HasPrefix(strings.ToLower(s.Name), strings.ToLower(query)) {
+					matched = append(matched, s)
+				}
+			}
+			if len(matched) == 1 {
+				text, _, err := logPRNIntake(log, user, matched[0])
+				if err != nil {
+					return c.Send("Ошибка при сохранении приёма.")
+				}
+				if err := c.Send(text); err != nil {
+					return err
+				}
+				announceAchievements(c, log, user)
+				return nil
+			}
+			if len(matched) > 1 {
+				supplements = matched
+			}
+		}
+
+		markup := &tele.ReplyMarkup{}
+		var rows []tele.Row
+		logs, err := loadIntakeLogs(user, nowDate(), nowDate())
+		if err != nil {
+			return c.Send("Ошибка при получении приёмов.")
+		}
+		for _, s := range supplements {
+			rows = append(rows, markup.Row(callback.Button(markup, prnButtonLabel(s, prnTakenCount(logs, s.ID)), "prn_take", callback.ID(s.ID))))
+		}
+		markup.Inline(rows...)
+		return c.Send("💊 Что принял(а)?", markup)
+	}
+}
@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/callback"
+	"DailyDoseBot/internal/db"
+	"DailyDoseBot/internal/models"
+	"DailyDoseBot/internal/schedule"
+	"DailyDoseBot/internal/utils"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+	"gorm.io/gorm"
+)
+
+// Действия кнопок настроек отчётов
+const (
+	reportToggleWeekly  = "w"
+	reportToggleMonthly = "m"
+	reportSetWeekday    = "d"
+	reportSetTime       = "t"
+	reportAskTime       = "c"
+	reportAskTimezone   = "z"
+)
+
+// Время отчёта на выбор одной кнопкой
+var reportTimePresets = []string{"07:00", "09:00", "12:00", "18:00", "21:00"}
+
+// Смещение от UTC в целых часах: "+3", "-5", "UTC+3"
+var utcOffsetRegex = regexp.MustCompile(`^(?i:utc|gmt)?\s*([+-])(\d{1,2})$`)
+
+// Часовой пояс пользователя; пустой или неизвестный — время сервера
+func userLocation(user models.User) *time.Location {
+	if user.Timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// Разбирает часовой пояс: имя IANA (Europe/Moscow) или целое смещение (+3, UTC-5);
+// "-" или "сервер" — пустая строка, то есть время сервера
+func parseTimezone(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	if m := utcOffsetRegex.FindStringSubmatch(text); m != nil {
+		hours, _ := strconv.Atoi(m[2])
+		if hours > 14 {
+			return "", false
+		}
+		if hours == 0 {
+			return "UTC", true
+		}
+		// В зонах Etc/GMT знак инвертирован: UTC+3 — это Etc/GMT-3
+		sign := "-"
+		if m[1] == "-" {
+			sign = "+"
+		}
+		return fmt.Sprintf("Etc/GMT%s%d", sign, hours), true
+	}
+	// Сброс к времени сервера
+	if text == "-" || strings.EqualFold(text, "сервер") || strings.EqualFold(text, "local") {
+		return "", true
+	}
+	if text == "" {
+		return "", false
+	}
+	if _, err := time.LoadLocation(text); err != nil {
+		return "", false
+	}
+	return text, true
+}
+
+// Следующий недельный отчёт после now: день недели и время в поясе пользователя
+func nextWeeklyReport(user models.User, now time.Time) time.Time {
+	loc := userLocation(user)
+	local := now.In(loc)
+	clock, _ := time.Parse("15:04", user.ReportTime)
+	candidate := time.Date(local.Year(), local.Month(), local.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+	candidate = candidate.AddDate(0, 0, (user.ReportWeekday-schedule.Weekday(candidate)+7)%7)
+	if !candidate.After(now) {
+		candidate = candidate.AddDate(0, 0, 7)
+	}
+	return candidate
+}
+
+// Следующий месячный отчёт после now: первое число месяца в то же время
+func nextMonthlyReport(user models.User, now time.Time) time.Time {
+	loc := userLocation(user)
+	local := now.In(loc)
+	clock, _ := time.Parse("15:04", user.ReportTime)
+	candidate := time.Date(local.Year(), local.Month(), 1, clock.Hour(), clock.Minute(), 0, 0, loc)
+	if !candidate.After(now) {
+		candidate = candidate.AddDate(0, 1, 0)
+	}
+	return candidate
+}
+
+// Пересчитывает время следующих отчётов по текущим настройкам
+func planReports(user *models.User, now time.Time) {
+	user.NextReportAt, user.NextMonthlyReportAt = nil, nil
+	if user.ReportWeekly {
+		next := nextWeeklyReport(*user, now)
+		user.NextReportAt = &next
+	}
+	if user.ReportMonthly {
+		next := nextMonthlyReport(*user, now)
+		user.NextMonthlyReportAt = &next
+	}
+}
+
+// Сохраняет настройки отчётов вместе с пересчитанным расписанием
+func saveReportSettings(user *models.User) error {
+	return saveReportSettingsTx(db.DB, user)
+}
+
+// То же в рамках транзакции
+func saveReportSettingsTx(tx *gorm.DB, user *models.User) error {
+	planReports(user, time.Now())
+	return tx.Model(user).Updates(map[string]interface{}{
+		"report_weekly":          user.ReportWeekly,
+		"report_monthly":         user.ReportMonthly,
+		"report_weekday":         user.ReportWeekday,
+		"report_time":            user.ReportTime,
+		"timezone":               user.Timezone,
+		"next_report_at":         user.NextReportAt,
+		"next_monthly_report_at": user.NextMonthlyReportAt,
+	}).Error
+}
+
+// Отправляет отчёты, время которых подошло, и планирует следующие.
+// Вызывается по cron каждые несколько минут; у каждого пользователя своё расписание.
+func SendDueReports(bot *tele.Bot, log *zap.Logger, now time.Time) {
+	// Пользователям без расписания (новым или после миграции) сначала планируем отчёты
+	var unplanned []models.User
+	if err := db.DB.Where("(report_weekly AND next_report_at IS NULL) OR (report_monthly AND next_monthly_report_at IS NULL)").Find(&unplanned).Error; err != nil {
+		log.Error("Ошибка получения пользователей", zap.Error(err))
+		return
+	}
+	for i := range unplanned {
+		if err := saveReportSettings(&unplanned[i]); err != nil {
+			log.Error("Не удалось запланировать отчёты", zap.Error(err))
+		}
+	}
+
+	var weekly []models.User
+	if err := db.DB.Where("report_weekly AND next_report_at <= ?", now).Find(&weekly).Error; err != nil {
+		log.Error("Ошибка получения пользователей", zap.Error(err))
+		return
+	}
+	for _, user := range weekly {
+		// Сначала сдвигаем расписание, чтобы сбой отправки не приводил к повторам каждые несколько минут
+		next := nextWeeklyReport(user, now)
+		if err := db.DB.Model(&user).Update("next_report_at", next).Error; err != nil {
+			log.Error("Не удалось запланировать недельный отчёт", zap.Error(err))
+			continue
+		}
+		msg, err := buildStatsMessageForUser(user, now)
+		if err != nil {
+			log.Error("Ошибка построения недельного отчёта", zap.Int64("telegram_id", user.TelegramID), zap.Error(err))
+			continue
+		}
+		if _, err := bot.Send(&tele.User{ID: user.TelegramID}, msg, &tele.SendOptions{ParseMode: tele.ModeMarkdown}); err != nil {
+			log.Warn("Не удалось отправить статистику", zap.Int64("telegram_id", user.TelegramID), zap.Error(err))
+		}
+	}
+
+	var monthly []models.User
+	if err := db.DB.Where("report_monthly AND next_monthly_report_at <= ?", now).Find(&monthly).Error; err != nil {
+		log.Error("Ошибка получения пользователей", zap.Error(err))
+		return
+	}
+	for _, user := range monthly {
+		next := nextMonthlyReport(user, now)
+		if err := db.DB.Model(&user).Update("next_monthly_report_at", next).Error; err != nil {
+			log.Error("Не удалось запланировать месячный отчёт", zap.Error(err))
+			continue
+		}
+		// Отчёт за прошлый месяц — календарь выполнения с итогами в подписи
+		local := now.In(userLocation(user))
+		month := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+		photo, _, err := buildHeatmap(user, month)
+		if err != nil {
+			log.Error("Ошибка построения месячного отчёта", zap.Error(err))
+			continue
+		}
+		photo.Caption = "📬 Месячный отчёт\n\n" + photo.Caption
+		if _, err := bot.Send(&tele.User{ID: user.TelegramID}, photo); err != nil {
+			log.Warn("Не удалось отправить месячный отчёт", zap.Int64("telegram_id", user.TelegramID), zap.Error(err))
+		}
+	}
+}
+
+// Название пояса для настроек
+func timezoneLabel(user models.User) string {
+	if user.Timezone == "" {
+		return "время сервера"
+	}
+	return user.Timezone
+}
+
+// Строит сообщение /report с текущими настройками и кнопками
+func buildReportSettings(user models.User) (string, *tele.ReplyMarkup) {
+	weekdaysRu := []string{"Пн", "Вт", "Ср", "Чт", "Пт", "Сб", "Вс"}
+	var sb strings.Builder
+	sb.WriteString("📬 Отчёты\n\n")
+	if user.ReportWeekly {
+		sb.WriteString(fmt.Sprintf("Недельный: %s в %s\n", weekdaysRu[user.ReportWeekday], user.ReportTime))
+	} else {
+		sb.WriteString("Недельный: выключен\n")
+	}
+	if user.ReportMonthly {
+		sb.WriteString(fmt.Sprintf("Месячный: 1-го числа в %s\n", user.ReportTime))
+	} else {
+		sb.WriteString("Месячный: выключен\n")
+	}
+	sb.WriteString("Часовой пояс: " + timezoneLabel(user))
+	if user.NextReportAt != nil || user.NextMonthlyReportAt != nil {
+		next := user.NextReportAt
+		if next == nil || (user.NextMonthlyReportAt != nil && user.NextMonthlyReportAt.Before(*next)) {
+			next = user.NextMonthlyReportAt
+		}
+		sb.WriteString("\nСледующий отчёт: " + next.In(userLocation(user)).Format("02.01.2006 15:04"))
+	}
+
+	markup := &tele.ReplyMarkup{}
+	weeklyLabel := "✅ Недельный"
+	if !user.ReportWeekly {
+		weeklyLabel = "❌ Недельный"
+	}
+	monthlyLabel := "✅ Месячный"
+	if !user.ReportMonthly {
+		monthlyLabel = "❌ Месячный"
+	}
+	rows := []tele.Row{markup.Row(
+		callback.Button(markup, weeklyLabel, "report", reportToggleWeekly, ""),
+		callback.Button(markup, monthlyLabel, "report", reportToggleMonthly, ""),
+	)}
+	var days []tele.Btn
+	for i, name := range weekdaysRu {
+		if i == user.ReportWeekday {
+			name = "• " + name
+		}
+		days = append(days, callback.Button(markup, name, "report", reportSetWeekday, strconv.Itoa(i)))
+	}
+	rows = append(rows, markup.Row(days...))
+	var times []tele.Btn
+	for _, t := range reportTimePresets {
+		label := t
+		if t == user.ReportTime {
+			label = "• " + t
+		}
+		times = append(times, callback.Button(markup, label, "report", reportSetTime, t))
+	}
+	rows = append(rows, markup.Row(times...))
+	rows = append(rows, markup.Row(
+		callback.Button(markup, "✏️ Другое время", "report", reportAskTime, ""),
+		callback.Button(markup, "🌍 Часовой пояс", "report", reportAskTimezone, ""),
+	))
+	markup.Inline(rows...)
+	return sb.String(), markup
+}
+
+// /report — настройка недельного и месячного отчётов
+func ReportSettingsHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		var user models.User
+		if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+			return c.Send("Пользователь не найден.")
+		}
+		msg, markup := buildReportSettings(user)
+		return c.Send(msg, markup)
+	}
+}
+
+// Callback-хендлер кнопок настроек отчётов
+func HandleReportCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		fields, err := callback.Decode(c.Callback(), 2) // действие, значение
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		var user models.User
+		if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Пользователь не найден"})
+		}
+		switch fields[0] {
+		case reportToggleWeekly:
+			user.ReportWeekly = !user.ReportWeekly
+		case reportToggleMonthly:
+			user.ReportMonthly = !user.ReportMonthly
+		case reportSetWeekday:
+			day, err := strconv.Atoi(fields[1])
+			if err != nil || day < 0 || day > 6 {
+				return respondBadCallback(c, log, callback.ErrMalformed)
+			}
+			user.ReportWeekday = day
+		case reportSetTime:
+			if !clockRegex.MatchString(fields[1]) {
+				return respondBadCallback(c, log, callback.ErrMalformed)
+			}
+			user.ReportTime = fields[1]
+		case reportAskTime:
+			setPendingInput(c.Sender().ID, &PendingInput{Kind: inputReportTime})
+			_ = c.Respond()
+			return c.Send("Во сколько присылать отчёт? Введи время в формате ЧЧ:ММ, например 08:30", utils.CancelKeyboard())
+		case reportAskTimezone:
+			setPendingInput(c.Sender().ID, &PendingInput{Kind: inputTimezone})
+			_ = c.Respond()
+			return c.Send("Введи часовой пояс: название вроде Europe/Moscow или смещение от UTC, например +3. Чтобы вернуть время сервера, отправь «-» или «сервер»", utils.CancelKeyboard())
+		default:
+			return respondBadCallback(c, log, callback.ErrMalformed)
+		}
+		if err := saveReportSettings(&user); err != nil {
+			log.Error("Ошибка сохранения настроек отчётов", zap.Error(err))
+			return c.Respond(&tele.CallbackResponse{Text: "Ошибка сохранения"})
+		}
+		msg, markup := buildReportSettings(user)
+		_ = c.Edit(msg, markup)
+		return c.Respond()
+	}
+}
+
+// Обрабатывает введённое время отчёта или часовой пояс
+func handleReportInput(c tele.Context, log *zap.Logger, input *PendingInput) error {
+	var user models.User
+	if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+		return c.Send("Пользователь не найден.")
+	}
+	text := strings.TrimSpace(c.Text())
+	switch input.Kind {
+	case inputReportTime:
+		if !clockRegex.MatchString(text) {
+			setPendingInput(c.Sender().ID, input)
+			return c.Send("Не понял время. Введи в формате ЧЧ:ММ, например 08:30")
+		}
+		// Храним с ведущим нулём, как и остальные времена
+		clock, _ := time.Parse("15:04", text)
+		user.ReportTime = clock.Format("15:04")
+	case inputTimezone:
+		tz, ok := parseTimezone(text)
+		if !ok {
+			setPendingInput(c.Sender().ID, input)
+			return c.Send("Не знаю такого часового пояса. Пример: Europe/Moscow, +3 или «-» для времени сервера")
+		}
+		user.Timezone = tz
+	}
+	if err := saveReportSettings(&user); err != nil {
+		log.Error("Ошибка сохранения настроек отчётов", zap.Error(err))
+		return c.Send("Ошибка при сохранении настроек.")
+	}
+	msg, markup := buildReportSettings(user)
+	if err := c.Send("Сохранено ✅", utils.MainMenuKeyboard()); err != nil {
+		return err
+	}
+	return c.Send(msg, markup)
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/models"
+	"testing"
+	"time"
+)
+
+func utc(y int, m time.Month, d, h, min int) time.Time {
+	return time.Date(y, m, d, h, min, 0, 0, time.UTC)
+}
+
+func TestNextWeeklyReport(t *testing.T) {
+	tests := []struct {
+		name string
+		user models.User
+		now  time.Time
+		want time.Time
+	}{
+		{"сегодня, время ещё не наступило", models.User{Timezone: "UTC", ReportWeekday: 0, ReportTime: "09:00"},
+			utc(2026, 10, 19, 8, 0), utc(2026, 10, 19, 9, 0)},
+		{"ровно в момент отчёта — через неделю", models.User{Timezone: "UTC", ReportWeekday: 0, ReportTime: "09:00"},
+			utc(2026, 10, 19, 9, 0), utc(2026, 10, 26, 9, 0)},
+		{"воскресенье с понедельника", models.User{Timezone: "UTC", ReportWeekday: 6, ReportTime: "20:30"},
+			utc(2026, 10, 19, 12, 0), utc(2026, 10, 25, 20, 30)},
+		// В Токио уже понедельник 08:30, в UTC ещё воскресенье
+		{"пояс впереди UTC", models.User{Timezone: "Asia/Tokyo", ReportWeekday: 0, ReportTime: "09:00"},
+			utc(2026, 10, 18, 23, 30), utc(2026, 10, 19, 0, 0)},
+		{"пояс впереди UTC, день отчёта прошёл", models.User{Timezone: "Asia/Tokyo", ReportWeekday: 6, ReportTime: "20:00"},
+			utc(2026, 10, 18, 12, 0), utc(2026, 10, 25, 11, 0)},
+		// В Нью-Йорке 1 ноября кончается летнее время: 09:00 по местному — уже 14:00 UTC
+		{"переход на зимнее время", models.User{Timezone: "America/New_York", ReportWeekday: 0, ReportTime: "09:00"},
+			utc(2026, 10, 27, 0, 0), utc(2026, 11, 2, 14, 0)},
+		{"смещение без имени пояса", models.User{Timezone: "Etc/GMT+5", ReportWeekday: 4, ReportTime: "07:00"},
+			utc(2026, 10, 23, 11, 59), utc(2026, 10, 23, 12, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextWeeklyReport(tt.user, tt.now); !got.Equal(tt.want) {
+				t.Errorf("nextWeeklyReport() = %s, want %s", got.UTC(), tt.want)
+			}
+		})
+	}
+}
+
+func TestNextMonthlyReport(t *testing.T) {
+	tests := []struct {
+		name string
+		user models.User
+		now  time.Time
+		want time.Time
+	}{
+		{"середина месяца", models.User{Timezone: "UTC", ReportTime: "09:00"},
+			utc(2026, 10, 19, 12, 0), utc(2026, 11, 1, 9, 0)},
+		{"первое число, время ещё не наступило", models.User{Timezone: "UTC", ReportTime: "09:00"},
+			utc(2026, 10, 1, 8, 0), utc(2026, 10, 1, 9, 0)},
+		{"переход через год", models.User{Timezone: "UTC", ReportTime: "07:00"},
+			utc(2026, 12, 15, 0, 0), utc(2027, 1, 1, 7, 0)},
+		// В Токио уже 1 ноября 05:00, в UTC ещё 31 октября
+		{"пояс впереди UTC", models.User{Timezone: "Asia/Tokyo", ReportTime: "09:00"},
+			utc(2026, 10, 31, 20, 0), utc(2026, 11, 1, 0, 0)},
+		// В Нью-Йорке ещё 31 октября, в UTC уже 1 ноября
+		{"пояс позади UTC", models.User{Timezone: "America/New_York", ReportTime: "21:00"},
+			utc(2026, 11, 1, 0, 30), utc(2026, 11, 2, 2, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextMonthlyReport(tt.user, tt.now); !got.Equal(tt.want) {
+				t.Errorf("nextMonthlyReport() = %s, want %s", got.UTC(), tt.want)
+			}
+		})
+	}
+}
+
+// Прошлая неделя считается по календарю пользователя, а не сервера
+func TestLastWeekStart(t *testing.T) {
+	tests := []struct {
+		name     string
+		timezone string
+		now      time.Time
+		want     time.Time
+	}{
+		// Понедельник 07:00 в Токио — воскресенье 22:00 по UTC
+		{"Токио, утро понедельника", "Asia/Tokyo", utc(2026, 10, 18, 22, 0), utc(2026, 10, 12, 0, 0)},
+		{"UTC в тот же момент", "UTC", utc(2026, 10, 18, 22, 0), utc(2026, 10, 5, 0, 0)},
+		// Воскресенье 23:00 в Нью-Йорке — уже понедельник по UTC
+		{"Нью-Йорк, вечер воскресенья", "America/New_York", utc(2026, 10, 19, 3, 0), utc(2026, 10, 5, 0, 0)},
+		{"UTC, понедельник", "UTC", utc(2026, 10, 19, 3, 0), utc(2026, 10, 12, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lastWeekStart(models.User{Timezone: tt.timezone}, tt.now)
+			if !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Errorf("lastWeekStart() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -35,7 +35,7 @@ func StartHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
 			return c.Send(msg, menu)
 		}
 
-		msg := fmt.Sprintf("👋 Привет снова, %s!\n\nРады видеть тебя! Я продолжаю следить за твоим прогрессом и напоминать о приёме добавок.\n\nНе забывай пользоваться командами:\n• /add — добавить добавку\n• /list — список добавок\n• /log — отметить приём\n• /status — статус и прогресс за сегодня\n\nКаждый понедельник я пришлю тебе недельную статистику! 💪", user.Name)
+		msg := fmt.Sprintf("👋 Привет снова, %s!\n\nРады видеть тебя! Я продолжаю следить за твоим прогрессом и напоминать о приёме добавок.\n\nНе забывай пользоваться командами:\n• /add — добавить добавку\n• /list — список добавок\n• /log — отметить приём\n• /status — статус и прогресс за сегодня\n\nКаждый понедельник я пришлю тебе недельную статистику! 💪 День, время и часовой пояс можно поменять в /report", user.Name)
 		return c.Send(msg, menu)
 	}
 }
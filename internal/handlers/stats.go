@@ -3,221 +3,76 @@ package handlers
 import (
 	"DailyDoseBot/internal/db"
 	"DailyDoseBot/internal/models"
-	"encoding/json"
+	"DailyDoseBot/internal/schedule"
 	"fmt"
 	"strings"
 	"time"
-
-	"go.uber.org/zap"
-	tele "gopkg.in/telebot.v4"
 )
 
-// Отправляет автоматическую статистику всем пользователям
-func SendWeeklyStats(bot *tele.Bot, log *zap.Logger) {
-	var users []models.User
-	if err := db.DB.Find(&users).Error; err != nil {
-		log.Error("Ошибка получения пользователей", zap.Error(err))
-		return
-	}
-
-	for _, user := range users {
-		msg := buildStatsMessageForUser(user)
-		if msg == "" {
-			continue
-		}
-		_, err := bot.Send(&tele.User{ID: user.TelegramID}, msg, &tele.SendOptions{ParseMode: tele.ModeMarkdown})
-		if err != nil {
-			log.Warn("Не удалось отправить статистику", zap.Int64("telegram_id", user.TelegramID), zap.Error(err))
-		}
-	}
+// Понедельник прошлой полной недели по календарю пользователя на момент now
+func lastWeekStart(user models.User, now time.Time) time.Time {
+	today := schedule.Date(now.In(userLocation(user)))
+	return weekStart(today).AddDate(0, 0, -7)
 }
 
 // Строит сообщение статистики для одного пользователя
-func buildStatsMessageForUser(user models.User) string {
-	// Определяем предыдущую полную неделю (понедельник-воскресенье)
-	today := time.Now().Truncate(24 * time.Hour)
-	weekday := int(today.Weekday())
-	if weekday == 0 {
-		weekday = 6 // Go: Sunday=0, а у нас Вс=6
-	} else {
-		weekday-- // Go: Monday=1, а у нас Пн=0
+func buildStatsMessageForUser(user models.User, now time.Time) (string, error) {
+	report, err := collectWeeklyReport(user, lastWeekStart(user, now))
+	if err != nil {
+		return "", err
 	}
-	// Начало предыдущей недели (понедельник)
-	start := today.AddDate(0, 0, -weekday-7)
-	end := start.AddDate(0, 0, 6)
-	days := 7
-	completedDays := 0
-	var progressBar string
-	for i := 0; i < days; i++ {
-		day := start.AddDate(0, 0, i)
-		dayWeekday := int(day.Weekday())
-		if dayWeekday == 0 {
-			dayWeekday = 6
-		} else {
-			dayWeekday--
-		}
-		var supplements []models.Supplement
-		if err := db.DB.Where("user_id = ?", user.ID).Find(&supplements).Error; err != nil {
-			continue
-		}
-		totalIntakes := 0
-		completedIntakes := 0
-		for _, s := range supplements {
-			if s.StartDate.After(day) {
-				continue
-			}
-			if s.EndDate != nil && s.EndDate.Before(day) {
-				continue
-			}
-			if len(s.DaysOfWeek) > 2 {
-				var daysOfWeek []int
-				_ = json.Unmarshal([]byte(s.DaysOfWeek), &daysOfWeek)
-				found := false
-				for _, d := range daysOfWeek {
-					if d == dayWeekday {
-						found = true
-						break
-					}
-				}
-				if !found {
-					continue
-				}
-			}
-			var times []string
-			if s.ReminderEnabled && len(s.ReminderTimes) > 2 {
-				_ = json.Unmarshal([]byte(s.ReminderTimes), &times)
-			}
-			if len(times) == 0 {
-				totalIntakes++
-				var logEntry models.IntakeLog
-				err := db.DB.Where("user_id = ? AND supplement_id = ? AND intake_date = ?", user.ID, s.ID, day).First(&logEntry).Error
-				if err == nil && logEntry.Taken {
-					completedIntakes++
-				}
-			} else {
-				for _, t := range times {
-					totalIntakes++
-					var logEntry models.IntakeLog
-					err := db.DB.Where("user_id = ? AND supplement_id = ? AND intake_date = ? AND intake_time = ?", user.ID, s.ID, day, t).First(&logEntry).Error
-					if err == nil && logEntry.Taken {
-						completedIntakes++
-					}
-				}
-			}
-		}
-		if totalIntakes > 0 && completedIntakes == totalIntakes {
-			progressBar += "🟩"
-			completedDays++
-		} else if completedIntakes > 0 {
-			progressBar += "🟨"
-		} else {
-			progressBar += "🟥"
-		}
-	}
-	if progressBar == "" {
-		return ""
-	}
-	percent := 0
-	if days > 0 {
-		percent = int(float64(completedDays) / float64(days) * 100)
-	}
-	msg := fmt.Sprintf("📈 *Твоя статистика за прошлую неделю (с %s по %s):*\n\n%s\n\n✅ Полностью выполнено: %d/%d дней (%d%%)\n\n🟩 – полностью выполнено\n🟨 – частично выполнено\n🟥 – не выполнено\n\nПродолжай формировать привычку и заботиться о здоровье 🚀",
-		start.Format("02.01"), end.Format("02.01"), progressBar, completedDays, days, percent)
-	return msg
+	return renderWeeklyReport(report), nil
 }
 
-// Тестовая функция для отладки статистики: отправляет подробный отчёт только одному пользователю
-func SendDebugStats(bot *tele.Bot, userID int64) {
+// Подробный отчёт по дням за прошлую неделю для диагностики (/admin debug)
+func buildDebugStats(user models.User) (string, error) {
 	// Определяем предыдущую полную неделю (понедельник-воскресенье)
-	today := time.Now().Truncate(24 * time.Hour)
-	weekday := int(today.Weekday())
-	if weekday == 0 {
-		weekday = 6
-	} else {
-		weekday--
+	start := lastWeekStart(user, time.Now())
+	end := start.AddDate(0, 0, 6)
+	var supplements []models.Supplement
+	if err := db.DB.Where("user_id = ?", user.ID).Find(&supplements).Error; err != nil {
+		return "", err
 	}
-	start := today.AddDate(0, 0, -weekday-7)
-	days := 7
-	var user models.User
-	if err := db.DB.First(&user, "telegram_id = ?", userID).Error; err != nil {
-		bot.Send(&tele.User{ID: userID}, "Пользователь не найден")
-		return
+	logs, err := loadIntakeLogs(user, start, end)
+	if err != nil {
+		return "", err
 	}
 	var sb strings.Builder
 	sb.WriteString("🛠️ DEBUG: Подробная статистика за прошлую неделю\n\n")
 	weekdaysRu := []string{"Пн", "Вт", "Ср", "Чт", "Пт", "Сб", "Вс"}
-	for i := 0; i < days; i++ {
-		day := start.AddDate(0, 0, i)
-		dayWeekday := int(day.Weekday())
-		if dayWeekday == 0 {
-			dayWeekday = 6
-		} else {
-			dayWeekday--
-		}
-		dateStr := day.Format("2006-01-02")
-		var supplements []models.Supplement
-		if err := db.DB.Where("user_id = ?", user.ID).Find(&supplements).Error; err != nil {
-			sb.WriteString(dateStr + ": ошибка получения добавок\n")
-			continue
-		}
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
 		totalIntakes := 0
 		completedIntakes := 0
+		partialIntakes := 0
 		for _, s := range supplements {
-			if s.StartDate.After(day) {
-				continue
-			}
-			if s.EndDate != nil && s.EndDate.Before(day) {
-				continue
-			}
-			if len(s.DaysOfWeek) > 2 {
-				var daysOfWeek []int
-				_ = json.Unmarshal([]byte(s.DaysOfWeek), &daysOfWeek)
-				found := false
-				for _, d := range daysOfWeek {
-					if d == dayWeekday {
-						found = true
-						break
-					}
-				}
-				if !found {
-					continue
-				}
-			}
-			var times []string
-			if s.ReminderEnabled && len(s.ReminderTimes) > 2 {
-				_ = json.Unmarshal([]byte(s.ReminderTimes), &times)
-			}
-			if len(times) == 0 {
+			// Приём по необходимости и дни вне курса не входят в план
+			for _, occ := range schedule.Expand(s, day, day) {
+				t := occ.Time
 				totalIntakes++
-				var logEntry models.IntakeLog
-				err := db.DB.Where("user_id = ? AND supplement_id = ? AND intake_date = ?", user.ID, s.ID, day).First(&logEntry).Error
-				if err == nil && logEntry.Taken {
-					completedIntakes++
-					sb.WriteString(fmt.Sprintf("✅ %s — принято (%s)\n", s.Name, s.Dosage))
-				} else {
-					sb.WriteString(fmt.Sprintf("❌ %s — не принято (%s)\n", s.Name, s.Dosage))
+				logEntry, ok := logs[doseKey(s.ID, day, t)]
+				label := doseLabel(s.Name, t)
+				if t == "" {
+					label += " (" + s.Dosage + ")"
 				}
-			} else {
-				for _, t := range times {
-					totalIntakes++
-					var logEntry models.IntakeLog
-					err := db.DB.Where("user_id = ? AND supplement_id = ? AND intake_date = ? AND intake_time = ?", user.ID, s.ID, day, t).First(&logEntry).Error
-					if err == nil && logEntry.Taken {
-						completedIntakes++
-						sb.WriteString(fmt.Sprintf("✅ %s (%s) — принято\n", s.Name, t))
-					} else {
-						sb.WriteString(fmt.Sprintf("❌ %s (%s) — не принято\n", s.Name, t))
-					}
+				switch {
+				case ok && logEntry.IsPartial():
+					partialIntakes++
+					sb.WriteString(fmt.Sprintf("🌓 %s — частично, %s\n", label, partialText(logEntry)))
+				case ok && logEntry.Taken:
+					completedIntakes++
+					sb.WriteString(fmt.Sprintf("✅ %s — принято\n", label))
+				default:
+					sb.WriteString(fmt.Sprintf("❌ %s — не принято\n", label))
 				}
 			}
 		}
 		status := "🟥"
 		if totalIntakes > 0 && completedIntakes == totalIntakes {
 			status = "🟩"
-		} else if completedIntakes > 0 {
+		} else if completedIntakes > 0 || partialIntakes > 0 {
 			status = "🟨"
 		}
-		sb.WriteString(fmt.Sprintf("%s %s %s: %d/%d выполнено\n\n", status, dateStr, weekdaysRu[dayWeekday], completedIntakes, totalIntakes))
+		sb.WriteString(fmt.Sprintf("%s %s %s: %d/%d выполнено, частично %d\n\n", status, day.Format("2006-01-02"), weekdaysRu[schedule.Weekday(day)], completedIntakes, totalIntakes, partialIntakes))
 	}
-	bot.Send(&tele.User{ID: userID}, sb.String())
+	return sb.String(), nil
 }
@@ -3,10 +3,9 @@ package handlers
 import (
 	"DailyDoseBot/internal/db"
 	"DailyDoseBot/internal/models"
-	"encoding/json"
+	"DailyDoseBot/internal/schedule"
 	"fmt"
 	"strings"
-	"time"
 
 	tele "gopkg.in/telebot.v4"
 )
@@ -27,71 +26,71 @@ func StatusHandler(b *tele.Bot) func(c tele.Context) error {
 			return c.Send("У тебя пока нет добавок.")
 		}
 
-		today := time.Now().Truncate(24 * time.Hour)
+		today := nowDate()
+		// Все отметки за сегодня одним запросом, дальше считаем в памяти
+		logs, err := loadIntakeLogs(user, today, today)
+		if err != nil {
+			return c.Send("Ошибка при получении приёмов.")
+		}
 		totalIntakes := 0
 		completedIntakes := 0
+		partialIntakes := 0
+		portions := 0.0
 		var lines []string
 
+		var prnLines []string
 		for _, s := range supplements {
-			// Проверяем, нужно ли принимать сегодня
-			addToday := true
-			if len(s.DaysOfWeek) > 2 {
-				var daysOfWeek []int
-				_ = json.Unmarshal([]byte(s.DaysOfWeek), &daysOfWeek)
-				todayWeekday := int(today.Weekday())
-				if todayWeekday == 0 {
-					todayWeekday = 6 // Go: Sunday=0, а у нас Вс=6
-				} else {
-					todayWeekday-- // Go: Monday=1, а у нас Пн=0
-				}
-				found := false
-				for _, d := range daysOfWeek {
-					if d == todayWeekday {
-						found = true
-						break
-					}
-				}
-				if !found {
-					addToday = false
-				}
-			}
-			if !addToday {
-				continue
-			}
-			var times []string
-			if s.ReminderEnabled && len(s.ReminderTimes) > 2 {
-				_ = json.Unmarshal([]byte(s.ReminderTimes), &times)
-			}
-			if len(times) == 0 {
-				totalIntakes++
-				var logEntry models.IntakeLog
-				err := db.DB.Where("user_id = ? AND supplement_id = ? AND intake_date = ?", user.ID, s.ID, today).First(&logEntry).Error
-				if err == nil && logEntry.Taken {
-					completedIntakes++
-					lines = append(lines, fmt.Sprintf("✅ %s — принято", s.Name))
-				} else {
-					lines = append(lines, fmt.Sprintf("❌ %s — не принято", s.Name))
+			// Приём по необходимости не входит в план, показываем отдельно
+			if s.IsPRN() {
+				if count := prnTakenCount(logs, s.ID); count > 0 {
+					prnLines = append(prnLines, fmt.Sprintf("💊 %s — %d раз(а)", s.Name, count))
 				}
 				continue
 			}
-			// Несколько времён
-			for _, t := range times {
+			// Дозы на сегодня по расписанию
+			for _, occ := range schedule.Expand(s, today, today) {
+				t := occ.Time
 				totalIntakes++
-				var logEntry models.IntakeLog
-				err := db.DB.Where("user_id = ? AND supplement_id = ? AND intake_date = ? AND intake_time = ?", user.ID, s.ID, today, t).First(&logEntry).Error
-				if err == nil && logEntry.Taken {
+				logEntry, ok := logs[doseKey(s.ID, today, t)]
+				switch {
+				case ok && logEntry.IsPartial():
+					partialIntakes++
+					portions += logEntry.Portion()
+					if t == "" {
+						lines = append(lines, fmt.Sprintf("🌓 %s — частично, %s", s.Name, partialText(logEntry)))
+					} else {
+						lines = append(lines, fmt.Sprintf("🌓 %s (%s) — %s", s.Name, t, partialText(logEntry)))
+					}
+				case ok && logEntry.Taken:
 					completedIntakes++
-					lines = append(lines, fmt.Sprintf("✅ %s (%s)", s.Name, t))
-				} else {
-					lines = append(lines, fmt.Sprintf("❌ %s (%s)", s.Name, t))
+					portions++
+					if t == "" {
+						lines = append(lines, fmt.Sprintf("✅ %s — принято", s.Name))
+					} else {
+						lines = append(lines, fmt.Sprintf("✅ %s (%s)", s.Name, t))
+					}
+				default:
+					if t == "" {
+						lines = append(lines, fmt.Sprintf("❌ %s — не принято", s.Name))
+					} else {
+						lines = append(lines, fmt.Sprintf("❌ %s (%s)", s.Name, t))
+					}
 				}
 			}
 		}
+		// Частичные приёмы учитываются долей принятой дозы
 		percent := 0
 		if totalIntakes > 0 {
-			percent = int(float64(completedIntakes) / float64(totalIntakes) * 100)
+			percent = int(portions / float64(totalIntakes) * 100)
+		}
+		done := fmt.Sprintf("Выполнено: %d", completedIntakes)
+		if partialIntakes > 0 {
+			done += fmt.Sprintf("\nЧастично: %d", partialIntakes)
+		}
+		msg := fmt.Sprintf("📊 Статус на сегодня:\n\nВсего приёмов: %d\n%s\n\n%s\n\nПрогресс: %d%%", totalIntakes, done, strings.Join(lines, "\n"), percent)
+		if len(prnLines) > 0 {
+			msg += "\n\nПо необходимости:\n" + strings.Join(prnLines, "\n")
 		}
-		msg := fmt.Sprintf("📊 Статус на сегодня:\n\nВсего приёмов: %d\nВыполнено: %d\n\n%s\n\nПрогресс: %d%%", totalIntakes, completedIntakes, strings.Join(lines, "\n"), percent)
 		return c.Send(msg)
 	}
 }
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/callback"
+	"DailyDoseBot/internal/db"
+	"DailyDoseBot/internal/intake"
+	"DailyDoseBot/internal/models"
+	"DailyDoseBot/internal/utils"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v4"
+)
+
+// Период, за который считается точность приёма
+const timingPeriodDays = 30
+
+// Откуда нажата кнопка "В другое время"
+const (
+	takenAtFromReminder = "r"
+	takenAtFromLog      = "l"
+)
+
+var clockRegex = regexp.MustCompile(`^(?:[01]?\d|2[0-3]):[0-5]\d$`)
+
+// Момент времени "ЧЧ:ММ" в указанный день (по локальному времени сервера, как и напоминания)
+func atClock(day time.Time, clock string) (time.Time, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, err
+	}
+	d := day.UTC()
+	return time.Date(d.Year(), d.Month(), d.Day(), t.Hour(), t.Minute(), 0, 0, time.Local), nil
+}
+
+// Callback-хендлер кнопки "В другое время": просит ввести фактическое время приёма
+func HandleIntakeOtherTimeCallback(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		fields, err := callback.Decode(c.Callback(), 4) // ID добавки, время, дата, источник
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		suppUUID, err := callback.ParseID(fields[0])
+		if err != nil {
+			return respondBadCallback(c, log, err)
+		}
+		day, err := parseLogDay(fields[2])
+		if err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Этот день недоступен"})
+		}
+		var user models.User
+		if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "Пользователь не найден"})
+		}
+		supplement, err := ownedSupplement(log, user, suppUUID)
+		if err != nil {
+			return respondSupplementError(c, err)
+		}
+		source := auditSourceLog
+		if fields[3] == takenAtFromReminder {
+			source = auditSourceReminder
+		}
+		setPendingInput(c.Sender().ID, &PendingInput{
+			Kind:         inputTakenAt,
+			SupplementID: supplement.ID,
+			IntakeTime:   fields[1],
+			Date:         day,
+			Source:       source,
+		})
+		_ = c.Respond()
+		return c.Send(fmt.Sprintf("🕒 Во сколько ты принял(а) %s %s?\n\nНапиши время в формате ЧЧ:ММ, например: 09:15", doseLabel(supplement.Name, fields[1]), utils.FormatDateRu(day)), utils.CancelKeyboard())
+	}
+}
+
+// Обрабатывает введённое фактическое время приёма
+func handleTakenAtInput(c tele.Context, log *zap.Logger, input *PendingInput) error {
+	clock := strings.TrimSpace(c.Text())
+	if !clockRegex.MatchString(clock) {
+		setPendingInput(c.Sender().ID, input)
+		return c.Send("❌ Неверный формат времени. Напиши время в формате ЧЧ:ММ, например: 09:15", utils.CancelKeyboard())
+	}
+	takenAt, err := atClock(input.Date, clock)
+	if err != nil {
+		setPendingInput(c.Sender().ID, input)
+		return c.Send("❌ Неверный формат времени. Напиши время в формате ЧЧ:ММ, например: 09:15", utils.CancelKeyboard())
+	}
+	if takenAt.After(time.Now()) {
+		setPendingInput(c.Sender().ID, input)
+		return c.Send("❌ Это время ещё не наступило. Укажи время, когда приём уже был.", utils.CancelKeyboard())
+	}
+	var user models.User
+	if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+		return c.Send("Пользователь не найден.")
+	}
+	supplement, err := ownedSupplement(log, user, input.SupplementID)
+	if err != nil {
+		return c.Send("Добавка не найдена.")
+	}
+	logEntry, err := intake.Record(intake.Dose{UserID: user.ID, SupplementID: supplement.ID, Date: input.Date, Time: input.IntakeTime}, intake.Status{
+		Taken:      true,
+		TakenAt:    &takenAt,
+		Backfilled: input.Date.Before(nowDate()),
+	})
+	if err != nil {
+		log.Error("Ошибка сохранения приёма", zap.Error(err))
+		return c.Send("Ошибка при сохранении приёма.")
+	}
+	auditIntake(log, logEntry, auditActionTaken, input.Source)
+	if err := c.Send(fmt.Sprintf("✅ Приём %s отмечен в %s", doseLabel(supplement.Name, input.IntakeTime), clock), utils.MainMenuKeyboard()); err != nil {
+		return err
+	}
+	announceAchievements(c, log, user)
+	return nil
+}
+
+// Медиана отсортированного среза
+func medianMinutes(sorted []float64) float64 {
+	n := len(sorted)
+	if n == 0 {
+		return 0
+	}
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// Задержка в виде "+12 мин" / "−5 мин" / "1 ч 30 мин"
+func formatDelay(minutes float64) string {
+	sign := "+"
+	if minutes < 0 {
+		sign = "−"
+		minutes = -minutes
+	}
+	m := int(minutes + 0.5)
+	if m >= 60 {
+		return fmt.Sprintf("%s%d ч %d мин", sign, m/60, m%60)
+	}
+	return fmt.Sprintf("%s%d мин", sign, m)
+}
+
+// /timing — медианная задержка и доля своевременных приёмов по добавкам и времени
+func TimingHandler(b *tele.Bot, log *zap.Logger) func(c tele.Context) error {
+	return func(c tele.Context) error {
+		var user models.User
+		if err := db.DB.First(&user, "telegram_id = ?", c.Sender().ID).Error; err != nil {
+			return c.Send("Пользователь не найден.")
+		}
+		var supplements []models.Supplement
+		if err := db.DB.Where("user_id = ?", user.ID).Find(&supplements).Error; err != nil {
+			return c.Send("Ошибка при получении добавок.")
+		}
+		names := make(map[string]string, len(supplements))
+		for _, s := range supplements {
+			names[s.ID.String()] = s.Name
+		}
+
+		from := nowDate().AddDate(0, 0, -timingPeriodDays)
+		var logs []models.IntakeLog
+		if err := db.DB.Where("user_id = ? AND taken = ? AND taken_at IS NOT NULL AND intake_time <> '' AND intake_date >= ?", user.ID, true, from).Find(&logs).Error; err != nil {
+			log.Error("Ошибка получения приёмов", zap.Error(err))
+			return c.Send("Ошибка при получении приёмов.")
+		}
+
+		// Задержки в минутах по паре (добавка, время приёма)
+		type slotKey struct{ supplementID, slot string }
+		delays := make(map[slotKey][]float64)
+		for _, l := range logs {
+			scheduled, err := atClock(l.IntakeDate, l.IntakeTime)
+			if err != nil {
+				continue
+			}
+			key := slotKey{l.SupplementID.String(), l.IntakeTime}
+			delays[key] = append(delays[key], l.TakenAt.Sub(scheduled).Minutes())
+		}
+		if len(delays) == 0 {
+			return c.Send("⏱ Пока нет данных о фактическом времени приёма.\n\nОтмечай приёмы кнопками в напоминаниях или указывай время через «🕒 В другое время».")
+		}
+
+		keys := make([]slotKey, 0, len(delays))
+		for k := range delays {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if names[keys[i].supplementID] != names[keys[j].supplementID] {
+				return names[keys[i].supplementID] < names[keys[j].supplementID]
+			}
+			return keys[i].slot < keys[j].slot
+		})
+
+		window := float64(appConfig.OnTimeWindow)
+		var lines []string
+		for _, k := range keys {
+			d := delays[k]
+			sort.Float64s(d)
+			onTime := 0
+			for _, v := range d {
+				if v >= -window && v <= window {
+					onTime++
+				}
+			}
+			lines = append(lines, fmt.Sprintf("• %s %s — медиана %s, вовремя %d%% (%d приёмов)",
+				names[k.supplementID], k.slot, formatDelay(medianMinutes(d)), onTime*100/len(d), len(d)))
+		}
+		msg := fmt.Sprintf("⏱ Точность приёма за %d дней (допуск ±%d мин):\n\n%s", timingPeriodDays, appConfig.OnTimeWindow, strings.Join(lines, "\n"))
+		return c.Send(msg)
+	}
+}
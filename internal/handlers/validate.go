@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Правила ввода полей добавки, общие для мастера /add и массового добавления /bulk
+
+var (
+	errNameEmpty      = errors.New("не указано название")
+	errDosageEmpty    = errors.New("не указана дозировка")
+	errIntakeTime     = errors.New("неизвестное время приёма")
+	errWithFood       = errors.New("с едой — нужно «да» или «нет»")
+	errStartDate      = errors.New("дата начала не в формате ГГГГ-ММ-ДД")
+	errDurationFormat = errors.New("срок — число недель (3), месяцев (2м) или «-»")
+	errDays           = errors.New("неизвестный день недели")
+	errPRNDays        = errors.New("для приёма по необходимости дни недели не указываются")
+	errReminderFormat = errors.New("время напоминания не в формате ЧЧ:ММ")
+	errReminderStep   = errors.New("время напоминания должно быть кратно 30 минутам")
+	errMaxDoses       = errors.New("максимум приёмов — положительное число или «-»")
+)
+
+var (
+	durationWeeksRegex  = regexp.MustCompile(`^\d+$`)
+	durationMonthsRegex = regexp.MustCompile(`^(\d+)[мМ]$`)
+	reminderTimeRegex   = regexp.MustCompile(`^(?:[01]\d|2[0-3]):[0-5]\d$`)
+)
+
+// Время приёма словами и кодами из кнопок мастера
+var intakeTimeAliases = map[string]string{
+	"утро": "morning", "morning": "morning",
+	"день": "afternoon", "afternoon": "afternoon",
+	"вечер": "evening", "evening": "evening",
+	"любое": "any", "любое время": "any", "any": "any", "": "any",
+}
+
+// Дни недели: сокращения и полные названия, Пн=0
+var weekdayAliases = map[string]int{
+	"пн": 0, "понедельник": 0, "mon": 0,
+	"вт": 1, "вторник": 1, "tue": 1,
+	"ср": 2, "среда": 2, "wed": 2,
+	"чт": 3, "четверг": 3, "thu": 3,
+	"пт": 4, "пятница": 4, "fri": 4,
+	"сб": 5, "суббота": 5, "sat": 5,
+	"вс": 6, "воскресенье": 6, "sun": 6,
+}
+
+// Название добавки
+func parseSupplementName(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", errNameEmpty
+	}
+	return s, nil
+}
+
+// Дозировка в свободной форме
+func parseDosage(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", errDosageEmpty
+	}
+	return s, nil
+}
+
+// Время приёма: morning, afternoon, evening, any; prn — приём по необходимости
+func parseIntakeTime(s string) (intakeTime string, prn bool, err error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "по необходимости", "prn":
+		return "any", true, nil
+	}
+	if v, ok := intakeTimeAliases[s]; ok {
+		return v, false, nil
+	}
+	return "", false, errIntakeTime
+}
+
+// Принимать с едой; пусто — нет
+func parseWithFood(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "да", "yes", "true", "1", "+", "✅":
+		return true, nil
+	case "нет", "no", "false", "0", "", "-", "❌":
+		return false, nil
+	}
+	return false, errWithFood
+}
+
+// Дата начала в формате мастера; пусто или «сегодня» — сегодня
+func parseStartDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.EqualFold(s, "сегодня") {
+		return nowDate(), nil
+	}
+	parsed, err := parseDate(s)
+	if err != nil {
+		return time.Time{}, errStartDate
+	}
+	return parsed, nil
+}
+
+// Срок приёма от даты начала: "3" — недели, "2м" — месяцы, "-" или пусто — бессрочно
+func parseCourseEnd(s string, start time.Time) (*time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "-" || s == "" {
+		return nil, nil
+	}
+	var end time.Time
+	if durationWeeksRegex.MatchString(s) {
+		weeks, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, errDurationFormat
+		}
+		end = start.AddDate(0, 0, weeks*7)
+	} else if m := durationMonthsRegex.FindStringSubmatch(s); m != nil {
+		months, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, errDurationFormat
+		}
+		end = start.AddDate(0, months, 0)
+	} else {
+		return nil, errDurationFormat
+	}
+	return &end, nil
+}
+
+// Дни недели через запятую или пробел; пусто или «каждый день» — все дни
+func parseWeekdays(s string) ([]int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" || s == "каждый день" || s == "ежедневно" {
+		return []int{0, 1, 2, 3, 4, 5, 6}, nil
+	}
+	seen := make(map[int]bool)
+	var days []int
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		d, ok := weekdayAliases[part]
+		if !ok {
+			return nil, errDays
+		}
+		if !seen[d] {
+			seen[d] = true
+			days = append(days, d)
+		}
+	}
+	sort.Ints(days)
+	return days, nil
+}
+
+// Времена напоминаний через запятую, кратные 30 минутам; «нет» или пусто — без напоминаний
+func parseReminderTimes(s string) ([]string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.EqualFold(s, "нет") {
+		return nil, nil
+	}
+	var times []string
+	for _, t := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if !reminderTimeRegex.MatchString(t) {
+			return nil, errReminderFormat
+		}
+		if minutes := t[3:]; minutes != "00" && minutes != "30" {
+			return nil, errReminderStep
+		}
+		times = append(times, t)
+	}
+	return times, nil
+}
+
+// Максимум приёмов в день для приёма по необходимости; "-" или пусто — без ограничения
+func parseMaxDoses(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "-" || s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, errMaxDoses
+	}
+	return n, nil
+}
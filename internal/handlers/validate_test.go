@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSupplementName(t *testing.T) {
+	tests := []struct {
+		in, want string
+		err      error
+	}{
+		{"Витамин D3", "Витамин D3", nil},
+		{"  Магний  ", "Магний", nil},
+		{"", "", errNameEmpty},
+		{"   ", "", errNameEmpty},
+	}
+	for _, tt := range tests {
+		got, err := parseSupplementName(tt.in)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("parseSupplementName(%q) = %q, %v, want %q, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestParseDosage(t *testing.T) {
+	tests := []struct {
+		in, want string
+		err      error
+	}{
+		{"2000 МЕ", "2000 МЕ", nil},
+		{" 1 таб. ", "1 таб.", nil},
+		{"", "", errDosageEmpty},
+		{"\t", "", errDosageEmpty},
+	}
+	for _, tt := range tests {
+		got, err := parseDosage(tt.in)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("parseDosage(%q) = %q, %v, want %q, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestParseIntakeTime(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantPRN bool
+		err     error
+	}{
+		{"утро", "morning", false, nil},
+		{"Вечер", "evening", false, nil},
+		{"afternoon", "afternoon", false, nil},
+		{"любое время", "any", false, nil},
+		{"", "any", false, nil},
+		{"По необходимости", "any", true, nil},
+		{"prn", "any", true, nil},
+		{"ночь", "", false, errIntakeTime},
+	}
+	for _, tt := range tests {
+		got, prn, err := parseIntakeTime(tt.in)
+		if got != tt.want || prn != tt.wantPRN || !errors.Is(err, tt.err) {
+			t.Errorf("parseIntakeTime(%q) = %q, %v, %v, want %q, %v, %v", tt.in, got, prn, err, tt.want, tt.wantPRN, tt.err)
+		}
+	}
+}
+
+func TestParseWithFood(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+		err  error
+	}{
+		{"да", true, nil},
+		{"Yes", true, nil},
+		{"✅", true, nil},
+		{"нет", false, nil},
+		{"", false, nil},
+		{"-", false, nil},
+		{"иногда", false, errWithFood},
+	}
+	for _, tt := range tests {
+		got, err := parseWithFood(tt.in)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("parseWithFood(%q) = %v, %v, want %v, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestParseStartDate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+		err  error
+	}{
+		{"2026-10-19", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), nil},
+		{"", nowDate(), nil},
+		{"Сегодня", nowDate(), nil},
+		{"19.10.2026", time.Time{}, errStartDate},
+		{"2026-02-30", time.Time{}, errStartDate},
+	}
+	for _, tt := range tests {
+		got, err := parseStartDate(tt.in)
+		if !got.Equal(tt.want) || !errors.Is(err, tt.err) {
+			t.Errorf("parseStartDate(%q) = %s, %v, want %s, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestParseCourseEnd(t *testing.T) {
+	start := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want *time.Time
+		err  error
+	}{
+		{"", nil, nil},
+		{"-", nil, nil},
+		{"3", ptrDate(start.AddDate(0, 0, 21)), nil},
+		{"2м", ptrDate(start.AddDate(0, 2, 0)), nil},
+		{"1М", ptrDate(start.AddDate(0, 1, 0)), nil},
+		{"2 недели", nil, errDurationFormat},
+		{"-3", nil, errDurationFormat},
+		{"99999999999999999999", nil, errDurationFormat},
+	}
+	for _, tt := range tests {
+		got, err := parseCourseEnd(tt.in, start)
+		if !errors.Is(err, tt.err) || (got == nil) != (tt.want == nil) || (got != nil && !got.Equal(*tt.want)) {
+			t.Errorf("parseCourseEnd(%q) = %v, %v, want %v, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestParseWeekdays(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []int
+		err  error
+	}{
+		{"", []int{0, 1, 2, 3, 4, 5, 6}, nil},
+		{"Каждый день", []int{0, 1, 2, 3, 4, 5, 6}, nil},
+		{"пн, ср, пт", []int{0, 2, 4}, nil},
+		{"пт пн", []int{0, 4}, nil},
+		{"Суббота,воскресенье", []int{5, 6}, nil},
+		{"mon, mon, пн", []int{0}, nil},
+		{"пн, выходные", nil, errDays},
+	}
+	for _, tt := range tests {
+		got, err := parseWeekdays(tt.in)
+		if !reflect.DeepEqual(got, tt.want) || !errors.Is(err, tt.err) {
+			t.Errorf("parseWeekdays(%q) = %v, %v, want %v, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestParseReminderTimes(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+		err  error
+	}{
+		{"", nil, nil},
+		{"Нет", nil, nil},
+		{"08:00", []string{"08:00"}, nil},
+		{"08:00, 20:30", []string{"08:00", "20:30"}, nil},
+		{"8:00", nil, errReminderFormat},
+		{"24:00", nil, errReminderFormat},
+		{"08:15", nil, errReminderStep},
+	}
+	for _, tt := range tests {
+		got, err := parseReminderTimes(tt.in)
+		if !reflect.DeepEqual(got, tt.want) || !errors.Is(err, tt.err) {
+			t.Errorf("parseReminderTimes(%q) = %v, %v, want %v, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestParseMaxDoses(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		err  error
+	}{
+		{"", 0, nil},
+		{"-", 0, nil},
+		{"3", 3, nil},
+		{" 2 ", 2, nil},
+		{"0", 0, errMaxDoses},
+		{"-1", 0, errMaxDoses},
+		{"три", 0, errMaxDoses},
+	}
+	for _, tt := range tests {
+		got, err := parseMaxDoses(tt.in)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("parseMaxDoses(%q) = %d, %v, want %d, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
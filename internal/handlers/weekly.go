@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/db"
+	"DailyDoseBot/internal/models"
+	"DailyDoseBot/internal/schedule"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Сколько недель до отчётной берётся для сравнения
+const trendWeeks = 4
+
+// Процент выполнения, ниже которого день недели считается трудным
+const poorWeekdayPercent = 60
+
+// Сколько недель из отчётной и предыдущих день должен быть трудным, чтобы попасть в отчёт
+const poorWeekdayMinWeeks = 3
+
+// Порог падения в процентных пунктах, о котором стоит сказать
+const trendDropThreshold = 10
+
+// Запланировано и принято доз за неделю или по срезу
+type weekTotals struct {
+	Planned int
+	Done    float64
+}
+
+func (w weekTotals) percent() int {
+	return percentOf(w.Done, w.Planned)
+}
+
+// Самое сильное падение выполнения по добавке или времени приёма
+type trendDrop struct {
+	Label         string
+	Before, After int
+}
+
+// Данные недельного отчёта; текст строит renderWeeklyReport без обращения к базе
+type weeklyReport struct {
+	Start, End     time.Time
+	Days           []dayProgress
+	Weeks          []weekTotals // [0] — отчётная неделя, [1] — предыдущая и так далее
+	SupplementDrop *trendDrop
+	SlotDrop       *trendDrop
+	PoorWeekdays   []int
+}
+
+// Собирает данные отчёта за неделю с start по start+6 и trendWeeks недель до неё двумя запросами
+func collectWeeklyReport(user models.User, start time.Time) (weeklyReport, error) {
+	end := start.AddDate(0, 0, 6)
+	var supplements []models.Supplement
+	if err := db.DB.Where("user_id = ?", user.ID).Order("name").Find(&supplements).Error; err != nil {
+		return weeklyReport{}, err
+	}
+	logs, err := loadIntakeLogs(user, start.AddDate(0, 0, -7*trendWeeks), end)
+	if err != nil {
+		return weeklyReport{}, err
+	}
+	return aggregateWeeklyReport(supplements, logs, start), nil
+}
+
+// Данные недельного отчёта по уже загруженным добавкам и записям за отчётную и trendWeeks предыдущих недель
+func aggregateWeeklyReport(supplements []models.Supplement, logs map[string]models.IntakeLog, start time.Time) weeklyReport {
+	end := start.AddDate(0, 0, 6)
+	from := start.AddDate(0, 0, -7*trendWeeks)
+	r := weeklyReport{Start: start, End: end, Weeks: make([]weekTotals, trendWeeks+1)}
+	names := make(map[uuid.UUID]string, len(supplements))
+	for _, s := range supplements {
+		names[s.ID] = s.Name
+	}
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		r.Days = append(r.Days, dayProgress{Date: day})
+	}
+
+	// Срезы по неделям: по добавкам, по времени приёма и по дням недели
+	bySupplement := make([]map[string]*weekTotals, trendWeeks+1)
+	bySlot := make([]map[string]*weekTotals, trendWeeks+1)
+	byWeekday := make([][7]weekTotals, trendWeeks+1)
+	for w := range bySupplement {
+		bySupplement[w] = make(map[string]*weekTotals)
+		bySlot[w] = make(map[string]*weekTotals)
+	}
+	add := func(m map[string]*weekTotals, key string, portion float64) {
+		if m[key] == nil {
+			m[key] = &weekTotals{}
+		}
+		m[key].Planned++
+		m[key].Done += portion
+	}
+	for _, occ := range schedule.ExpandAll(supplements, from, end) {
+		w := weekIndex(start, occ.Date)
+		entry, ok := logs[doseKey(occ.SupplementID, occ.Date, occ.Time)]
+		portion := entry.Portion()
+		r.Weeks[w].Planned++
+		r.Weeks[w].Done += portion
+		add(bySupplement[w], names[occ.SupplementID], portion)
+		if occ.Time != "" {
+			add(bySlot[w], doseLabel(names[occ.SupplementID], occ.Time), portion)
+		}
+		wd := schedule.Weekday(occ.Date)
+		byWeekday[w][wd].Planned++
+		byWeekday[w][wd].Done += portion
+		if w == 0 {
+			d := &r.Days[int(occ.Date.Sub(start).Hours()/24)]
+			d.Planned++
+			d.Done += portion
+			if ok && entry.IsPartial() {
+				d.Partial++
+			} else if ok && entry.Taken {
+				d.Taken++
+			}
+		}
+	}
+	r.SupplementDrop = biggestDrop(bySupplement[1], bySupplement[0])
+	r.SlotDrop = biggestDrop(bySlot[1], bySlot[0])
+
+	for wd := 0; wd < 7; wd++ {
+		poor := 0
+		for w := range byWeekday {
+			if t := byWeekday[w][wd]; t.Planned > 0 && t.percent() < poorWeekdayPercent {
+				poor++
+			}
+		}
+		if poor >= poorWeekdayMinWeeks {
+			r.PoorWeekdays = append(r.PoorWeekdays, wd)
+		}
+	}
+	return r
+}
+
+// Номер недели дня относительно отчётной недели с понедельником start: 0 — отчётная, 1 — предыдущая
+func weekIndex(start, day time.Time) int {
+	return int(start.Sub(day).Hours()/24+6) / 7
+}
+
+// Срез с наибольшим падением выполнения между неделями, если оно заметное
+func biggestDrop(before, after map[string]*weekTotals) *trendDrop {
+	var drop *trendDrop
+	for label, a := range after {
+		b, ok := before[label]
+		if !ok || b.Planned == 0 {
+			continue
+		}
+		diff := b.percent() - a.percent()
+		if diff < trendDropThreshold {
+			continue
+		}
+		// При равном падении — по алфавиту, чтобы отчёт не менялся от запуска к запуску
+		if drop == nil || diff > drop.Before-drop.After || (diff == drop.Before-drop.After && label < drop.Label) {
+			drop = &trendDrop{Label: label, Before: b.percent(), After: a.percent()}
+		}
+	}
+	return drop
+}
+
+// Стрелка изменения в процентных пунктах
+func trendArrow(now, before int) string {
+	switch diff := now - before; {
+	case diff >= 3:
+		return fmt.Sprintf("⬆️ +%d п.п.", diff)
+	case diff <= -3:
+		return fmt.Sprintf("⬇️ −%d п.п.", -diff)
+	default:
+		return "➡️ без изменений"
+	}
+}
+
+// Экранирует символы разметки Markdown в названиях добавок
+func escapeMarkdown(s string) string {
+	return strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[").Replace(s)
+}
+
+// Текст недельного отчёта; пустая строка — на неделе не было приёмов
+func renderWeeklyReport(r weeklyReport) string {
+	if len(r.Days) == 0 {
+		return ""
+	}
+	days := len(r.Days)
+	completedDays := 0
+	partialWeek := 0
+	var progressBar string
+	for _, d := range r.Days {
+		partialWeek += d.Partial
+		// Частичный приём не закрывает день полностью, но и не считается пропуском
+		if d.Planned > 0 && d.Taken == d.Planned {
+			progressBar += "🟩"
+			completedDays++
+		} else if d.Taken > 0 || d.Partial > 0 {
+			progressBar += "🟨"
+		} else {
+			progressBar += "🟥"
+		}
+	}
+	percent := int(float64(completedDays) / float64(days) * 100)
+	partialLine := ""
+	if partialWeek > 0 {
+		partialLine = fmt.Sprintf("\n🌓 Частичных приёмов: %d", partialWeek)
+	}
+
+	var trends strings.Builder
+	if week := r.Weeks[0]; week.Planned > 0 {
+		trends.WriteString(fmt.Sprintf("\n\n📊 Выполнение плана: %d%%", week.percent()))
+		if prev := r.Weeks[1]; prev.Planned > 0 {
+			trends.WriteString(fmt.Sprintf("\n%s к прошлой неделе (%d%%)", trendArrow(week.percent(), prev.percent()), prev.percent()))
+		}
+		var avg weekTotals
+		for _, w := range r.Weeks[1:] {
+			avg.Planned += w.Planned
+			avg.Done += w.Done
+		}
+		if avg.Planned > 0 {
+			trends.WriteString(fmt.Sprintf("\n%s к среднему за %d недели (%d%%)", trendArrow(week.percent(), avg.percent()), trendWeeks, avg.percent()))
+		}
+	}
+	if d := r.SupplementDrop; d != nil {
+		trends.WriteString(fmt.Sprintf("\n\n📉 Сильнее всего просела добавка %s: %d%% → %d%%", escapeMarkdown(d.Label), d.Before, d.After))
+	}
+	if d := r.SlotDrop; d != nil {
+		trends.WriteString(fmt.Sprintf("\n⏰ Время с наибольшим спадом: %s: %d%% → %d%%", escapeMarkdown(d.Label), d.Before, d.After))
+	}
+	if len(r.PoorWeekdays) > 0 {
+		weekdaysRu := []string{"Пн", "Вт", "Ср", "Чт", "Пт", "Сб", "Вс"}
+		var names []string
+		for _, wd := range r.PoorWeekdays {
+			names = append(names, weekdaysRu[wd])
+		}
+		trends.WriteString(fmt.Sprintf("\n📅 Стабильно трудные дни: %s (ниже %d%% минимум %d недели из %d)",
+			strings.Join(names, ", "), poorWeekdayPercent, poorWeekdayMinWeeks, trendWeeks+1))
+	}
+
+	return fmt.Sprintf("📈 *Твоя статистика за прошлую неделю (с %s по %s):*\n\n%s\n\n✅ Полностью выполнено: %d/%d дней (%d%%)%s%s\n\n🟩 – полностью выполнено\n🟨 – частично выполнено\n🟥 – не выполнено\n\nПродолжай формировать привычку и заботиться о здоровье 🚀",
+		r.Start.Format("02.01"), r.End.Format("02.01"), progressBar, completedDays, days, percent, partialLine, trends.String())
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"DailyDoseBot/internal/models"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// 2026-10-12 — понедельник отчётной недели
+var reportMonday = time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)
+
+func TestWeekIndex(t *testing.T) {
+	tests := []struct {
+		offset int // дней от понедельника отчётной недели
+		want   int
+	}{
+		{6, 0}, // воскресенье отчётной недели
+		{0, 0},
+		{-1, 1}, // воскресенье предыдущей
+		{-7, 1},
+		{-8, 2},
+		{-14, 2},
+		{-7 * trendWeeks, trendWeeks}, // первый день окна трендов
+	}
+	for _, tt := range tests {
+		day := reportMonday.AddDate(0, 0, tt.offset)
+		if got := weekIndex(reportMonday, day); got != tt.want {
+			t.Errorf("weekIndex(%s) = %d, want %d", day.Format(logDateFormat), got, tt.want)
+		}
+	}
+}
+
+func totals(planned int, done float64) *weekTotals {
+	return &weekTotals{Planned: planned, Done: done}
+}
+
+func TestBiggestDrop(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after map[string]*weekTotals
+		want          *trendDrop
+	}{
+		{"падение ниже порога", map[string]*weekTotals{"A": totals(100, 90)}, map[string]*weekTotals{"A": totals(100, 81)}, nil},
+		{"падение ровно на порог", map[string]*weekTotals{"A": totals(100, 90)}, map[string]*weekTotals{"A": totals(100, 80)}, &trendDrop{"A", 90, 80}},
+		{"рост", map[string]*weekTotals{"A": totals(10, 5)}, map[string]*weekTotals{"A": totals(10, 10)}, nil},
+		{"наибольшее из нескольких", map[string]*weekTotals{"A": totals(10, 10), "B": totals(10, 10), "C": totals(10, 9)},
+			map[string]*weekTotals{"A": totals(10, 8), "B": totals(10, 5), "C": totals(10, 3)}, &trendDrop{"C", 90, 30}},
+		{"при равном падении — по алфавиту", map[string]*weekTotals{"Б": totals(10, 10), "А": totals(10, 10), "В": totals(10, 10)},
+			map[string]*weekTotals{"Б": totals(10, 5), "В": totals(10, 5), "А": totals(10, 5)}, &trendDrop{"А", 100, 50}},
+		{"новая добавка без прошлой недели", map[string]*weekTotals{}, map[string]*weekTotals{"A": totals(10, 0)}, nil},
+		{"на прошлой неделе не было плана", map[string]*weekTotals{"A": totals(0, 0)}, map[string]*weekTotals{"A": totals(10, 0)}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Порядок обхода карты случаен — результат от него зависеть не должен
+			for i := 0; i < 20; i++ {
+				if got := biggestDrop(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("biggestDrop() = %+v, want %+v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestTrendArrow(t *testing.T) {
+	tests := []struct {
+		now, before int
+		want        string
+	}{
+		{80, 77, "⬆️ +3 п.п."},
+		{80, 78, "➡️ без изменений"},
+		{80, 80, "➡️ без изменений"},
+		{78, 80, "➡️ без изменений"},
+		{77, 80, "⬇️ −3 п.п."},
+		{0, 100, "⬇️ −100 п.п."},
+	}
+	for _, tt := range tests {
+		if got := trendArrow(tt.now, tt.before); got != tt.want {
+			t.Errorf("trendArrow(%d, %d) = %q, want %q", tt.now, tt.before, got, tt.want)
+		}
+	}
+}
+
+// Ежедневная добавка с одним приёмом; missed — смещения дней от понедельника отчётной недели без приёма
+func weekdayReport(missed []int, partial []int) weeklyReport {
+	supp := models.Supplement{ID: uuid.New(), Name: "Магний", StartDate: reportMonday.AddDate(0, 0, -60),
+		ReminderEnabled: true, ReminderTimes: datatypes.JSON(`["09:00"]`)}
+	skip := make(map[int]bool)
+	for _, d := range missed {
+		skip[d] = true
+	}
+	half := make(map[int]bool)
+	for _, d := range partial {
+		half[d] = true
+	}
+	var entries []models.IntakeLog
+	for offset := -7 * trendWeeks; offset < 7; offset++ {
+		if skip[offset] {
+			continue
+		}
+		e := models.IntakeLog{SupplementID: supp.ID, IntakeDate: reportMonday.AddDate(0, 0, offset), IntakeTime: "09:00", Taken: true}
+		if half[offset] {
+			e.QuantityTaken, e.QuantityPlanned = 1, 2
+		}
+		entries = append(entries, e)
+	}
+	return aggregateWeeklyReport([]models.Supplement{supp}, indexIntakeLogs(entries), reportMonday)
+}
+
+func TestPoorWeekdays(t *testing.T) {
+	tests := []struct {
+		name            string
+		missed, partial []int
+		want            []int
+	}{
+		{"без пропусков", nil, nil, nil},
+		{"понедельник пропущен 2 недели из 5", []int{0, -7}, nil, nil},
+		{"понедельник пропущен 3 недели из 5", []int{0, -14, -28}, nil, []int{0}},
+		{"половина дозы ниже порога", nil, []int{2, -5, -12}, []int{2}},
+		{"два дня", []int{6, -1, -8, 0, -7, -14, -21}, nil, []int{0, 6}},
+		{"пропуски на разные дни", []int{0, -6, -12, -18}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := weekdayReport(tt.missed, tt.partial).PoorWeekdays; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PoorWeekdays = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Границы недель: воскресенье отчётной недели в отчёте, воскресенье предыдущей — в тренде
+func TestAggregateWeeklyReportWeekBoundaries(t *testing.T) {
+	r := weekdayReport([]int{-1}, nil)
+	if got := r.Weeks[0]; got.Planned != 7 || got.Done != 7 {
+		t.Errorf("отчётная неделя = %+v, want 7/7", got)
+	}
+	if got := r.Weeks[1]; got.Planned != 7 || got.Done != 6 {
+		t.Errorf("предыдущая неделя = %+v, want 7/6", got)
+	}
+	if len(r.Days) != 7 || !r.Days[6].Date.Equal(reportMonday.AddDate(0, 0, 6)) || r.Days[6].Taken != 1 {
+		t.Errorf("дни отчётной недели: %+v", r.Days)
+	}
+}
+
+func TestEscapeMarkdown(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Витамин D3", "Витамин D3"},
+		{"vit_d", `vit\_d`},
+		{"*Омега*", `\*Омега\*`},
+		{"B`12", "B\\`12"},
+		{"[Цинк](x)", `\[Цинк](x)`},
+	}
+	for _, tt := range tests {
+		if got := escapeMarkdown(tt.in); got != tt.want {
+			t.Errorf("escapeMarkdown(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// Названия добавок в отчёте экранируются, остальная разметка остаётся
+func TestRenderWeeklyReportEscapesNames(t *testing.T) {
+	r := weekdayReport(nil, nil)
+	r.SupplementDrop = &trendDrop{Label: "vit_d *экстра*", Before: 90, After: 40}
+	r.SlotDrop = &trendDrop{Label: "[Цинк] (08:00)", Before: 80, After: 50}
+	got := renderWeeklyReport(r)
+	for _, want := range []string{`vit\_d \*экстра\*: 90% → 40%`, `\[Цинк] (08:00): 80% → 50%`, "📈 *Твоя статистика"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("в отчёте нет %q:\n%s", want, got)
+		}
+	}
+}
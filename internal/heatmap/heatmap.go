@@ -0,0 +1,156 @@
+package heatmap
+
+import (
+	"DailyDoseBot/internal/schedule"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"time"
+)
+
+// Календарь выполнения плана за месяц: сетка Пн–Вс, по строке на неделю,
+// цвет клетки — доля принятых доз за день (красный → жёлтый → зелёный).
+// Рисуется стандартной библиотекой, числа — встроенным пиксельным шрифтом.
+
+// Выполнение плана за один день месяца
+type Day struct {
+	Date    time.Time
+	Planned bool    // были ли запланированы приёмы
+	Ratio   float64 // доля выполненного плана от 0 до 1
+}
+
+// Размеры сетки в пикселях
+const (
+	cell      = 56
+	gap       = 4
+	margin    = 12
+	header    = 16 // полоса над сеткой: будни и выходные
+	fontScale = 3
+	padding   = 5 // отступ числа от угла клетки
+)
+
+var (
+	colorBackground = color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+	colorEmpty      = color.RGBA{0xEE, 0xEE, 0xEE, 0xFF} // приёмов не было
+	colorFuture     = color.RGBA{0xF8, 0xF8, 0xF8, 0xFF} // день ещё не наступил
+	colorBad        = color.RGBA{0xE5, 0x73, 0x73, 0xFF}
+	colorMiddle     = color.RGBA{0xFF, 0xD5, 0x4F, 0xFF}
+	colorGood       = color.RGBA{0x66, 0xBB, 0x6A, 0xFF}
+	colorText       = color.RGBA{0x33, 0x33, 0x33, 0xFF}
+	colorWeekday    = color.RGBA{0x90, 0xA4, 0xAE, 0xFF}
+	colorWeekend    = color.RGBA{0xEF, 0x9A, 0x9A, 0xFF}
+)
+
+// Цифры 3×5: по строке на каждые 3 пикселя, старший бит слева
+var digits = [10][5]uint8{
+	{7, 5, 5, 5, 7}, // 0
+	{2, 6, 2, 2, 7}, // 1
+	{7, 1, 7, 4, 7}, // 2
+	{7, 1, 7, 1, 7}, // 3
+	{5, 5, 7, 1, 1}, // 4
+	{7, 4, 7, 1, 7}, // 5
+	{7, 4, 7, 5, 7}, // 6
+	{7, 1, 1, 1, 1}, // 7
+	{7, 5, 7, 5, 7}, // 8
+	{7, 5, 7, 1, 7}, // 9
+}
+
+// Рисует календарь месяца, в который входит month, и пишет его в PNG.
+// Дни, которых нет в days, считаются ещё не наступившими.
+func Render(w io.Writer, month time.Time, days []Day) error {
+	return png.Encode(w, Draw(month, days))
+}
+
+// Рисует календарь месяца в изображение
+func Draw(month time.Time, days []Day) *image.RGBA {
+	first := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	count := first.AddDate(0, 1, -1).Day()
+	offset := schedule.Weekday(first)
+	weeks := (offset + count + 6) / 7
+
+	width := 2*margin + 7*cell + 6*gap
+	height := 2*margin + header + weeks*cell + (weeks-1)*gap
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{colorBackground}, image.Point{}, draw.Src)
+
+	// Полоса над столбцами: будни серые, выходные красные
+	for col := 0; col < 7; col++ {
+		c := colorWeekday
+		if col >= 5 {
+			c = colorWeekend
+		}
+		x := margin + col*(cell+gap)
+		fill(img, image.Rect(x, margin, x+cell, margin+header/3), c)
+	}
+
+	byDay := make(map[int]Day, len(days))
+	for _, d := range days {
+		if d.Date.Year() == first.Year() && d.Date.Month() == first.Month() {
+			byDay[d.Date.Day()] = d
+		}
+	}
+	for n := 1; n <= count; n++ {
+		pos := offset + n - 1
+		x := margin + (pos%7)*(cell+gap)
+		y := margin + header + (pos/7)*(cell+gap)
+		c := colorFuture
+		if d, ok := byDay[n]; ok {
+			c = colorEmpty
+			if d.Planned {
+				c = ratioColor(d.Ratio)
+			}
+		}
+		fill(img, image.Rect(x, y, x+cell, y+cell), c)
+		drawNumber(img, x+padding, y+padding, n)
+	}
+	return img
+}
+
+// Цвет клетки по доле выполнения: 0 — красный, 0.5 — жёлтый, 1 — зелёный
+func ratioColor(ratio float64) color.RGBA {
+	switch {
+	case ratio <= 0:
+		return colorBad
+	case ratio >= 1:
+		return colorGood
+	case ratio < 0.5:
+		return mix(colorBad, colorMiddle, ratio*2)
+	default:
+		return mix(colorMiddle, colorGood, (ratio-0.5)*2)
+	}
+}
+
+// Линейная смесь двух цветов
+func mix(a, b color.RGBA, t float64) color.RGBA {
+	ch := func(x, y uint8) uint8 {
+		return uint8(float64(x) + (float64(y)-float64(x))*t + 0.5)
+	}
+	return color.RGBA{ch(a.R, b.R), ch(a.G, b.G), ch(a.B, b.B), 0xFF}
+}
+
+func fill(img *image.RGBA, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, &image.Uniform{c}, image.Point{}, draw.Src)
+}
+
+// Пишет число пиксельным шрифтом, левый верхний угол — (x, y)
+func drawNumber(img *image.RGBA, x, y, n int) {
+	var ds []int
+	for ; n > 0 || len(ds) == 0; n /= 10 {
+		ds = append([]int{n % 10}, ds...)
+	}
+	for _, d := range ds {
+		for row, bits := range digits[d] {
+			for col := 0; col < 3; col++ {
+				if bits&(4>>col) == 0 {
+					continue
+				}
+				px := x + col*fontScale
+				py := y + row*fontScale
+				fill(img, image.Rect(px, py, px+fontScale, py+fontScale), colorText)
+			}
+		}
+		x += 4 * fontScale
+	}
+}
@@ -0,0 +1,110 @@
+package heatmap
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "перезаписать эталонные изображения в testdata")
+
+func day(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// Месяц, где встречаются все виды клеток: без приёмов, провалы, частичное и полное выполнение
+func sampleDays(y int, m time.Month, upTo int) []Day {
+	ratios := []float64{1, 0.75, 0.5, 0.25, 0, 1, 1}
+	var days []Day
+	for d := 1; d <= upTo; d++ {
+		days = append(days, Day{
+			Date:    day(y, m, d),
+			Planned: d%9 != 0,
+			Ratio:   ratios[d%len(ratios)],
+		})
+	}
+	return days
+}
+
+func TestRenderGolden(t *testing.T) {
+	tests := []struct {
+		name  string
+		month time.Time
+		days  []Day
+	}{
+		// Октябрь 2026 начинается в четверг, последняя неделя неполная; дни после 19-го ещё не наступили
+		{"october_2026_partial", day(2026, 10, 19), sampleDays(2026, 10, 19)},
+		// Февраль 2021 — ровно четыре недели с понедельника
+		{"february_2021_full", day(2021, 2, 1), sampleDays(2021, 2, 28)},
+		// Без данных: все дни будущие
+		{"march_2026_empty", day(2026, 3, 15), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Render(&buf, tt.month, tt.days); err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			golden := filepath.Join("testdata", tt.name+".png")
+			if *update {
+				if err := os.MkdirAll("testdata", 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("нет эталона %s (запусти go test -update): %v", golden, err)
+			}
+			// Сравниваем пиксели, а не байты: сжатие PNG может отличаться между версиями Go
+			got := decode(t, buf.Bytes())
+			exp := decode(t, want)
+			if got.Bounds() != exp.Bounds() {
+				t.Fatalf("размер %v, эталон %v", got.Bounds(), exp.Bounds())
+			}
+			b := got.Bounds()
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					r1, g1, b1, a1 := got.At(x, y).RGBA()
+					r2, g2, b2, a2 := exp.At(x, y).RGBA()
+					if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+						t.Fatalf("пиксель (%d, %d) отличается от эталона %s", x, y, golden)
+					}
+				}
+			}
+		})
+	}
+}
+
+func decode(t *testing.T, data []byte) image.Image {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("декодирование PNG: %v", err)
+	}
+	return img
+}
+
+func TestRatioColor(t *testing.T) {
+	tests := []struct {
+		ratio float64
+		want  string
+	}{
+		{-0.1, "bad"}, {0, "bad"}, {0.5, "middle"}, {1, "good"}, {1.2, "good"},
+	}
+	named := map[string]color.RGBA{"bad": colorBad, "middle": colorMiddle, "good": colorGood}
+	for _, tt := range tests {
+		if got := ratioColor(tt.ratio); got != named[tt.want] {
+			t.Errorf("ratioColor(%v) = %v, want %s", tt.ratio, got, tt.want)
+		}
+	}
+}
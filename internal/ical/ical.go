@@ -0,0 +1,184 @@
+package ical
+
+import (
+	"DailyDoseBot/internal/models"
+	"DailyDoseBot/internal/schedule"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Расписание добавок в формате iCalendar (RFC 5545).
+// Каждое время приёма — отдельное повторяющееся событие с напоминанием.
+// Время событий «плавающее» (без часового пояса): 08:00 в расписании
+// показывается как 08:00 на устройстве пользователя, где бы он ни был.
+
+// Форматы дат iCalendar
+const (
+	dateFormat     = "20060102"
+	dateTimeFormat = "20060102T150405"
+	utcFormat      = "20060102T150405Z"
+)
+
+// Длительность события приёма
+const eventDuration = "PT15M"
+
+// Максимальная длина строки в октетах без CRLF
+const lineLimit = 75
+
+// Дни недели RFC 5545 в нумерации бота: Пн=0 ... Вс=6
+var byDay = []string{"MO", "TU", "WE", "TH", "FR", "SA", "SU"}
+
+// Пишет календарь с событиями по всем добавкам по расписанию; now — отметка DTSTAMP
+func Write(w io.Writer, name string, supplements []models.Supplement, now time.Time) error {
+	cw := &writer{w: w}
+	cw.line("BEGIN:VCALENDAR")
+	cw.line("VERSION:2.0")
+	cw.line("PRODID:-//DailyDoseBot//Supplement schedule//RU")
+	cw.line("CALSCALE:GREGORIAN")
+	cw.line("METHOD:PUBLISH")
+	cw.line("X-WR-CALNAME:" + escape(name))
+	stamp := now.UTC().Format(utcFormat)
+	for _, s := range supplements {
+		// Приём по необходимости не имеет расписания, завершённый курс больше не напоминает
+		if s.IsPRN() || s.Completed {
+			continue
+		}
+		start, ok := firstDue(s)
+		if !ok {
+			continue
+		}
+		for _, t := range schedule.Times(s) {
+			writeEvent(cw, s, start, t, stamp)
+		}
+	}
+	cw.line("END:VCALENDAR")
+	return cw.err
+}
+
+// Первый день курса, в который добавку нужно принимать: DTSTART должен совпадать с правилом повторения
+func firstDue(s models.Supplement) (time.Time, bool) {
+	day := s.StartDate
+	for i := 0; i < 7; i++ {
+		if schedule.DueOn(s, day) {
+			return day, true
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return day, false
+}
+
+func writeEvent(cw *writer, s models.Supplement, start time.Time, clock string, stamp string) {
+	cw.line("BEGIN:VEVENT")
+	uid := s.ID.String()
+	if clock != "" {
+		uid += "-" + strings.ReplaceAll(clock, ":", "")
+	}
+	cw.line("UID:" + uid + "@dailydosebot")
+	cw.line("DTSTAMP:" + stamp)
+
+	// Без времени приёма — событие на весь день и без напоминания
+	allDay := clock == ""
+	if allDay {
+		cw.line("DTSTART;VALUE=DATE:" + start.Format(dateFormat))
+		cw.line("DTEND;VALUE=DATE:" + start.AddDate(0, 0, 1).Format(dateFormat))
+	} else {
+		at, _ := time.Parse("15:04", clock)
+		begin := time.Date(start.Year(), start.Month(), start.Day(), at.Hour(), at.Minute(), 0, 0, time.UTC)
+		cw.line("DTSTART:" + begin.Format(dateTimeFormat))
+		cw.line("DURATION:" + eventDuration)
+	}
+	cw.line("RRULE:" + rrule(s, allDay))
+
+	summary := "💊 " + s.Name
+	var description []string
+	if s.Dosage != "" {
+		description = append(description, "Дозировка: "+s.Dosage)
+	}
+	if s.WithFood {
+		description = append(description, "Принимать с едой")
+	}
+	cw.line("SUMMARY:" + escape(summary))
+	if len(description) > 0 {
+		cw.line("DESCRIPTION:" + escape(strings.Join(description, "\n")))
+	}
+	cw.line("TRANSP:TRANSPARENT")
+	if !allDay {
+		cw.line("BEGIN:VALARM")
+		cw.line("ACTION:DISPLAY")
+		cw.line("DESCRIPTION:" + escape(summary))
+		cw.line("TRIGGER:PT0M")
+		cw.line("END:VALARM")
+	}
+	cw.line("END:VEVENT")
+}
+
+// Правило повторения по дням недели и дате окончания курса
+func rrule(s models.Supplement, allDay bool) string {
+	var days []int
+	if len(s.DaysOfWeek) > 2 {
+		_ = json.Unmarshal([]byte(s.DaysOfWeek), &days)
+	}
+	rule := "FREQ=DAILY"
+	if len(days) > 0 && len(days) < 7 {
+		codes := make([]string, 0, len(days))
+		for _, d := range days {
+			if d >= 0 && d < len(byDay) {
+				codes = append(codes, byDay[d])
+			}
+		}
+		rule = "FREQ=WEEKLY;BYDAY=" + strings.Join(codes, ",")
+	}
+	if s.EndDate != nil {
+		// UNTIL того же вида, что DTSTART: дата или плавающее время, включая последний день
+		end := *s.EndDate
+		if allDay {
+			rule += ";UNTIL=" + end.Format(dateFormat)
+		} else {
+			rule += ";UNTIL=" + time.Date(end.Year(), end.Month(), end.Day(), 23, 59, 59, 0, time.UTC).Format(dateTimeFormat)
+		}
+	}
+	return rule
+}
+
+// Экранирование TEXT по RFC 5545: обратная косая черта, запятая, точка с запятой и переводы строк
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// Пишет строки с CRLF и переносом длинных строк; запоминает первую ошибку
+type writer struct {
+	w   io.Writer
+	err error
+}
+
+func (cw *writer) line(s string) {
+	if cw.err != nil {
+		return
+	}
+	_, cw.err = io.WriteString(cw.w, fold(s)+"\r\n")
+}
+
+// Переносит строку длиннее 75 октетов: продолжение начинается с пробела, символы UTF-8 не разрываются
+func fold(s string) string {
+	if len(s) <= lineLimit {
+		return s
+	}
+	var sb strings.Builder
+	limit := lineLimit
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		sb.WriteString(s[:cut])
+		sb.WriteString("\r\n ")
+		s = s[cut:]
+		// В строках продолжения один октет занимает ведущий пробел
+		limit = lineLimit - 1
+	}
+	sb.WriteString(s)
+	return sb.String()
+}
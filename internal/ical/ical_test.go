@@ -0,0 +1,217 @@
+package ical
+
+import (
+	"DailyDoseBot/internal/models"
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+var update = flag.Bool("update", false, "перезаписать эталонные календари в testdata")
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func datePtr(y int, m time.Month, d int) *time.Time {
+	t := date(y, m, d)
+	return &t
+}
+
+// Сравнивает календарь с эталоном побайтно: важны CRLF и переносы строк
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	golden := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("нет эталона %s (запусти go test -update): %v", golden, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s не совпадает с эталоном\n got: %q\nwant: %q", name, got, want)
+	}
+}
+
+func id(n byte) uuid.UUID {
+	return uuid.UUID{0x6f, 0x1c, 0x2b, 0x1e, 0, 0, 0x40, 0, 0x80, 0, 0, 0, 0, 0, 0, n}
+}
+
+// 2026-10-19 — понедельник
+func sampleSupplements() []models.Supplement {
+	return []models.Supplement{
+		{
+			// Каждый день в два времени, бессрочно
+			ID: id(1), Name: "Витамин D3", Dosage: "2000 МЕ", WithFood: true,
+			StartDate: date(2026, 10, 19), ReminderEnabled: true, ReminderTimes: datatypes.JSON(`["08:00","20:30"]`),
+		},
+		{
+			// Ср и Пт до конца ноября: курс начинается в понедельник, первое событие — в среду
+			ID: id(2), Name: "Магний", Dosage: "1 таб.", DaysOfWeek: datatypes.JSON(`[2,4]`),
+			StartDate: date(2026, 10, 19), EndDate: datePtr(2026, 11, 30),
+			ReminderEnabled: true, ReminderTimes: datatypes.JSON(`["09:00"]`),
+		},
+		{
+			// Без напоминаний — событие на весь день по выходным, без VALARM
+			ID: id(3), Name: "Железо", DaysOfWeek: datatypes.JSON(`[5,6]`),
+			StartDate: date(2026, 10, 19), EndDate: datePtr(2026, 12, 27), ReminderTimes: datatypes.JSON(`["10:00"]`),
+		},
+		{
+			// Все дни недели списком — то же, что каждый день
+			ID: id(4), Name: "Цинк", DaysOfWeek: datatypes.JSON(`[0,1,2,3,4,5,6]`),
+			StartDate: date(2026, 10, 20), ReminderEnabled: true, ReminderTimes: datatypes.JSON(`["21:00"]`),
+		},
+		{
+			// Длинное название с символами, которые нужно экранировать, и перенос строки в описании
+			ID: id(5), Name: "Омега-3; рыбий жир, капсулы \\ высокой концентрации для сердца и сосудов",
+			Dosage: "1 капсула", WithFood: true, StartDate: date(2026, 10, 19),
+			ReminderEnabled: true, ReminderTimes: datatypes.JSON(`["13:15"]`),
+		},
+		// Не попадают в календарь: по необходимости, завершённый курс и курс без подходящего дня
+		{ID: id(6), Name: "Ибупрофен", ScheduleType: models.SchedulePRN, StartDate: date(2026, 10, 19)},
+		{ID: id(7), Name: "Курс закончен", Completed: true, StartDate: date(2026, 9, 1)},
+		{ID: id(8), Name: "Пятница вне курса", DaysOfWeek: datatypes.JSON(`[4]`), StartDate: date(2026, 10, 19), EndDate: datePtr(2026, 10, 20)},
+	}
+}
+
+func TestWriteGolden(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Date(2026, 10, 19, 12, 30, 0, 0, time.FixedZone("MSK", 3*60*60))
+	if err := Write(&buf, "DailyDose: добавки", sampleSupplements(), now); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "schedule.ics", buf.Bytes())
+}
+
+func TestWriteEmptyGolden(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "DailyDose", nil, date(2026, 10, 19)); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "empty.ics", buf.Bytes())
+}
+
+func TestFirstDue(t *testing.T) {
+	tests := []struct {
+		name   string
+		s      models.Supplement
+		want   time.Time
+		wantOK bool
+	}{
+		{"каждый день", models.Supplement{StartDate: date(2026, 10, 19)}, date(2026, 10, 19), true},
+		{"первый день недели из списка", models.Supplement{StartDate: date(2026, 10, 19), DaysOfWeek: datatypes.JSON(`[2,4]`)}, date(2026, 10, 21), true},
+		{"переход через воскресенье", models.Supplement{StartDate: date(2026, 10, 24), DaysOfWeek: datatypes.JSON(`[0]`)}, date(2026, 10, 26), true},
+		{"через шесть дней", models.Supplement{StartDate: date(2026, 10, 20), DaysOfWeek: datatypes.JSON(`[0]`)}, date(2026, 10, 26), true},
+		{"курс кончается раньше", models.Supplement{StartDate: date(2026, 10, 19), EndDate: datePtr(2026, 10, 20), DaysOfWeek: datatypes.JSON(`[4]`)}, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := firstDue(tt.s)
+			if ok != tt.wantOK || (ok && !got.Equal(tt.want)) {
+				t.Errorf("firstDue() = %s, %v, want %s, %v", got.Format(dateFormat), ok, tt.want.Format(dateFormat), tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRRule(t *testing.T) {
+	tests := []struct {
+		name   string
+		s      models.Supplement
+		allDay bool
+		want   string
+	}{
+		{"каждый день", models.Supplement{}, false, "FREQ=DAILY"},
+		{"пустой список дней", models.Supplement{DaysOfWeek: datatypes.JSON(`[]`)}, false, "FREQ=DAILY"},
+		{"все дни списком", models.Supplement{DaysOfWeek: datatypes.JSON(`[0,1,2,3,4,5,6]`)}, false, "FREQ=DAILY"},
+		{"дни недели", models.Supplement{DaysOfWeek: datatypes.JSON(`[0,2,6]`)}, false, "FREQ=WEEKLY;BYDAY=MO,WE,SU"},
+		{"неверный номер дня пропускается", models.Supplement{DaysOfWeek: datatypes.JSON(`[1,9]`)}, false, "FREQ=WEEKLY;BYDAY=TU"},
+		{"окончание для события со временем", models.Supplement{EndDate: datePtr(2026, 11, 30)}, false, "FREQ=DAILY;UNTIL=20261130T235959"},
+		{"окончание для события на весь день", models.Supplement{EndDate: datePtr(2026, 11, 30)}, true, "FREQ=DAILY;UNTIL=20261130"},
+		{"дни недели с окончанием", models.Supplement{DaysOfWeek: datatypes.JSON(`[5,6]`), EndDate: datePtr(2026, 12, 27)}, true, "FREQ=WEEKLY;BYDAY=SA,SU;UNTIL=20261227"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rrule(tt.s, tt.allDay); got != tt.want {
+				t.Errorf("rrule() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Витамин D3", "Витамин D3"},
+		{"a,b;c", `a\,b\;c`},
+		{`C:\путь`, `C:\\путь`},
+		{"строка\nвторая", `строка\nвторая`},
+		{"строка\r\nвторая", `строка\nвторая`},
+		{`\,`, `\\\,`},
+	}
+	for _, tt := range tests {
+		if got := escape(tt.in); got != tt.want {
+			t.Errorf("escape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		lines []string
+	}{
+		{"ровно 75 октетов", strings.Repeat("a", 75), []string{strings.Repeat("a", 75)}},
+		{"76 октетов", strings.Repeat("a", 76), []string{strings.Repeat("a", 75), " a"}},
+		// 38 двухбайтовых «Ж» — 76 октетов, 75-й октет посередине символа
+		{"кириллица на границе", strings.Repeat("Ж", 38), []string{strings.Repeat("Ж", 37), " Ж"}},
+		// Префикс в 1 октет сдвигает границу: 1 + 37×2 = 75 октетов ровно
+		{"кириллица ровно по границе", "S" + strings.Repeat("Ж", 38), []string{"S" + strings.Repeat("Ж", 37), " Ж"}},
+		// Четырёхбайтовый символ на октетах 73–76 целиком уходит в продолжение
+		{"эмодзи на границе", strings.Repeat("a", 72) + "💊b", []string{strings.Repeat("a", 72), " 💊b"}},
+		{"продолжение тоже переносится", strings.Repeat("a", 75+74+1), []string{strings.Repeat("a", 75), " " + strings.Repeat("a", 74), " a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fold(tt.in); got != strings.Join(tt.lines, "\r\n") {
+				t.Errorf("fold() = %q, want %q", got, strings.Join(tt.lines, "\r\n"))
+			}
+		})
+	}
+}
+
+// Любая строка после переноса: не длиннее 75 октетов, целые символы UTF-8, склейка возвращает исходную
+func TestFoldLimits(t *testing.T) {
+	inputs := []string{
+		"SUMMARY:💊 " + strings.Repeat("Омега-3 ", 20),
+		"DESCRIPTION:" + strings.Repeat("日本語テキスト", 15),
+		strings.Repeat("💊", 50),
+		strings.Repeat("aЖ語💊", 30),
+	}
+	for _, in := range inputs {
+		folded := fold(in)
+		for _, line := range strings.Split(folded, "\r\n") {
+			if len(line) > lineLimit {
+				t.Errorf("строка длиннее %d октетов: %d", lineLimit, len(line))
+			}
+			if !utf8.ValidString(line) {
+				t.Errorf("разорван символ UTF-8: %q", line)
+			}
+		}
+		if got := strings.ReplaceAll(folded, "\r\n ", ""); got != in {
+			t.Errorf("склейка не совпадает с исходной строкой:\n got: %q\nwant: %q", got, in)
+		}
+	}
+}
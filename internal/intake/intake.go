@@ -0,0 +1,140 @@
+package intake
+
+import (
+	"DailyDoseBot/internal/db"
+	"DailyDoseBot/internal/models"
+	"DailyDoseBot/internal/schedule"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Сервис отметок о приёме. Запись о приёме уникальна по (supplement_id, intake_date, intake_time),
+// поэтому все изменения идут через upsert: повторное нажатие кнопки не создаёт дубликатов.
+
+// Ключ приёма: добавка, дата и время по расписанию
+type Dose struct {
+	UserID       uuid.UUID
+	SupplementID uuid.UUID
+	Date         time.Time
+	Time         string
+}
+
+// Статус приёма, который нужно записать
+type Status struct {
+	Taken           bool
+	Skipped         bool
+	Backfilled      bool
+	TakenAt         *time.Time
+	QuantityTaken   float64
+	QuantityPlanned float64
+}
+
+// Колонки уникального ключа приёма
+var doseColumns = []clause.Column{{Name: "supplement_id"}, {Name: "intake_date"}, {Name: "intake_time"}}
+
+func (d Dose) where(tx *gorm.DB) *gorm.DB {
+	return tx.Where("user_id = ? AND supplement_id = ? AND intake_date = ? AND intake_time = ?", d.UserID, d.SupplementID, d.Date, d.Time)
+}
+
+// Find возвращает запись о приёме; ok=false, если записи нет
+func Find(d Dose) (models.IntakeLog, bool, error) {
+	var entry models.IntakeLog
+	err := d.where(db.DB).First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return entry, false, nil
+	}
+	return entry, err == nil, err
+}
+
+// Record идемпотентно записывает статус приёма и возвращает актуальную запись.
+// Отметка "задним числом" не снимается, фактическое время сохраняется, пока приём отмечен.
+func Record(d Dose, st Status) (models.IntakeLog, error) {
+	entry := models.IntakeLog{
+		UserID:          d.UserID,
+		SupplementID:    d.SupplementID,
+		IntakeDate:      d.Date,
+		IntakeTime:      d.Time,
+		Taken:           st.Taken,
+		Skipped:         st.Skipped,
+		Backfilled:      st.Backfilled,
+		TakenAt:         st.TakenAt,
+		QuantityTaken:   st.QuantityTaken,
+		QuantityPlanned: st.QuantityPlanned,
+	}
+	err := db.DB.Clauses(clause.OnConflict{
+		Columns: doseColumns,
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"taken":            gorm.Expr("excluded.taken"),
+			"skipped":          gorm.Expr("excluded.skipped"),
+			"backfilled":       gorm.Expr("intake_logs.backfilled OR excluded.backfilled"),
+			"taken_at":         gorm.Expr("CASE WHEN excluded.taken THEN COALESCE(excluded.taken_at, intake_logs.taken_at) ELSE NULL END"),
+			"quantity_taken":   gorm.Expr("excluded.quantity_taken"),
+			"quantity_planned": gorm.Expr("excluded.quantity_planned"),
+		}),
+	}).Create(&entry).Error
+	if err != nil {
+		return entry, err
+	}
+	// При конфликте ID в entry не совпадает с существующей записью — перечитываем
+	entry, _, err = Find(d)
+	return entry, err
+}
+
+// Ensure возвращает запись о приёме, создавая пустую, если её нет (например, для заметки)
+func Ensure(d Dose) (models.IntakeLog, error) {
+	entry := models.IntakeLog{
+		UserID:       d.UserID,
+		SupplementID: d.SupplementID,
+		IntakeDate:   d.Date,
+		IntakeTime:   d.Time,
+		Backfilled:   d.Date.Before(schedule.Date(time.Now())),
+	}
+	if err := db.DB.Clauses(clause.OnConflict{Columns: doseColumns, DoNothing: true}).Create(&entry).Error; err != nil {
+		return entry, err
+	}
+	entry, _, err := Find(d)
+	return entry, err
+}
+
+// Remove снимает отметку о приёме и возвращает запись в состоянии до отмены; ok=false, если снимать было нечего.
+// Запись с заметкой или побочными эффектами остаётся, очищается только статус приёма.
+func Remove(d Dose) (models.IntakeLog, bool, error) {
+	var entry models.IntakeLog
+	found := false
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		err := d.where(tx).Clauses(clause.Locking{Strength: "UPDATE"}).First(&entry).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+		if !hasJournal(entry) {
+			return tx.Delete(&entry).Error
+		}
+		return tx.Model(&models.IntakeLog{}).Where("id = ?", entry.ID).Updates(map[string]interface{}{
+			"taken":            false,
+			"skipped":          false,
+			"taken_at":         nil,
+			"quantity_taken":   0,
+			"quantity_planned": 0,
+		}).Error
+	})
+	if err != nil || !found {
+		return models.IntakeLog{}, false, err
+	}
+	return entry, true, nil
+}
+
+// Есть ли у записи данные журнала: заметка или побочные эффекты
+func hasJournal(entry models.IntakeLog) bool {
+	var codes []string
+	_ = json.Unmarshal(entry.SideEffects, &codes)
+	return entry.Note != "" || len(codes) > 0
+}
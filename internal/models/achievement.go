@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Полученное пользователем достижение; каждое выдаётся один раз
+type Achievement struct {
+	ID        uuid.UUID `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UserID    uuid.UUID `gorm:"uniqueIndex:idx_achievements_user_code,priority:1;not null"`
+	Code      string    `gorm:"uniqueIndex:idx_achievements_user_code,priority:2;not null"`
+}
+
+func (a *Achievement) BeforeCreate(tx *gorm.DB) (err error) {
+	a.ID = uuid.New()
+	return
+}
+
+// День с пропуском, который закрыт заморозкой и не прерывает серию
+type StreakFreeze struct {
+	ID        uuid.UUID `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UserID    uuid.UUID `gorm:"uniqueIndex:idx_streak_freezes_user_date,priority:1;not null"`
+	Date      time.Time `gorm:"uniqueIndex:idx_streak_freezes_user_date,priority:2;not null"`
+}
+
+func (f *StreakFreeze) BeforeCreate(tx *gorm.DB) (err error) {
+	f.ID = uuid.New()
+	return
+}
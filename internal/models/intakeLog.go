@@ -4,17 +4,43 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
 type IntakeLog struct {
 	ID           uuid.UUID `gorm:"primaryKey"`
 	CreatedAt    time.Time
-	UserID       uuid.UUID `gorm:"index;not null"`
-	SupplementID uuid.UUID `gorm:"index;not null"`
-	IntakeDate   time.Time `gorm:"index;not null"` // Дата, за которую зафиксирован приём
-	IntakeTime   string    `gorm:"index;not null"` // Время приёма (например, "08:00" или "morning")
-	Taken        bool      `gorm:"default:false"`  // Был ли приём
+	UserID       uuid.UUID      `gorm:"index;not null"`
+	SupplementID uuid.UUID      `gorm:"index;not null;uniqueIndex:idx_intake_logs_dose,priority:1"`
+	IntakeDate   time.Time      `gorm:"index;not null;uniqueIndex:idx_intake_logs_dose,priority:2"` // Дата, за которую зафиксирован приём
+	IntakeTime   string         `gorm:"index;not null;uniqueIndex:idx_intake_logs_dose,priority:3"` // Время приёма (например, "08:00" или "morning")
+	Taken        bool           `gorm:"default:false"`                                              // Был ли приём
+	Skipped      bool           `gorm:"default:false"`                                              // Приём сознательно пропущен
+	Backfilled   bool           `gorm:"default:false"`                                              // Отмечено задним числом через /log
+	TakenAt      *time.Time     // Фактическое время приёма, nil если неизвестно
+	Note         string         // Заметка пользователя к приёму
+	SideEffects  datatypes.JSON // JSON массив кодов побочных эффектов: ["nausea"]
+	// Частичный приём: сколько единиц принято из запланированных (1 из 2 капсул).
+	// Нули — доза не измерялась и при Taken считается полной.
+	QuantityTaken   float64
+	QuantityPlanned float64
+}
+
+// Частичный ли приём: отмечен, но принято меньше запланированного
+func (s IntakeLog) IsPartial() bool {
+	return s.Taken && s.QuantityPlanned > 0 && s.QuantityTaken < s.QuantityPlanned
+}
+
+// Доля выполненного приёма: 1 — полностью, 0 — не принято
+func (s IntakeLog) Portion() float64 {
+	if !s.Taken {
+		return 0
+	}
+	if s.IsPartial() {
+		return s.QuantityTaken / s.QuantityPlanned
+	}
+	return 1
 }
 
 func (s *IntakeLog) BeforeCreate(tx *gorm.DB) (err error) {
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Журнал изменений отметок о приёме: кто, когда и что поменял
+type IntakeLogAudit struct {
+	ID           uuid.UUID `gorm:"primaryKey"`
+	CreatedAt    time.Time
+	UserID       uuid.UUID `gorm:"index;not null"`
+	SupplementID uuid.UUID `gorm:"index;not null"`
+	IntakeDate   time.Time `gorm:"not null"`
+	IntakeTime   string    `gorm:"not null"`
+	Action       string    `gorm:"not null"` // "taken", "partial", "skipped", "missed", "undo"
+	Source       string    `gorm:"not null"` // "reminder", "log"
+}
+
+func (a *IntakeLogAudit) BeforeCreate(tx *gorm.DB) (err error) {
+	a.ID = uuid.New()
+	return
+}
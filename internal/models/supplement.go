@@ -19,11 +19,25 @@ type Supplement struct {
 	WithFood        bool           // true если принимать с едой
 	DaysOfWeek      datatypes.JSON // JSON массив, например [1,3,5]
 	StartDate       time.Time
-	EndDate         *time.Time     // nil если бессрочно
-	ReminderTimes   datatypes.JSON // JSON массив строк: ["08:00","12:00"]
-	ReminderEnabled bool           `gorm:"default:true"`
-	Completed       bool           `gorm:"default:false"`
-	IntakeLogs      []IntakeLog    `gorm:"constraint:OnDelete:CASCADE"`
+	EndDate         *time.Time       // nil если бессрочно
+	ReminderTimes   datatypes.JSON   // JSON массив строк: ["08:00","12:00"]
+	ReminderEnabled bool             `gorm:"default:true"`
+	Completed       bool             `gorm:"default:false"`
+	ScheduleType    string           `gorm:"default:'scheduled'"` // "scheduled" — по расписанию, "prn" — по необходимости
+	MaxDailyDoses   int              // для "по необходимости": максимум приёмов в день, 0 — без ограничения
+	IntakeLogs      []IntakeLog      `gorm:"constraint:OnDelete:CASCADE"`
+	IntakeLogAudits []IntakeLogAudit `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// Типы расписания добавки
+const (
+	ScheduleRegular = "scheduled"
+	SchedulePRN     = "prn"
+)
+
+// Принимается ли добавка по необходимости: без напоминаний и без учёта в статистике
+func (s Supplement) IsPRN() bool {
+	return s.ScheduleType == SchedulePRN
 }
 
 func (s *Supplement) BeforeCreate(tx *gorm.DB) (err error) {
@@ -8,12 +8,27 @@ import (
 )
 
 type User struct {
-	ID          uuid.UUID `gorm:"primaryKey"`
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	TelegramID  int64 `gorm:"uniqueIndex;not null"` // Telegram user ID
-	Name        string
-	Supplements []Supplement `gorm:"constraint:OnDelete:CASCADE"`
+	ID         uuid.UUID `gorm:"primaryKey"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	TelegramID int64 `gorm:"uniqueIndex;not null"` // Telegram user ID
+	Name       string
+	// С какого дня действует заморозка серии; nil — заморозка выключена
+	StreakFreezeSince *time.Time
+	// Расписание отчётов: день недели (Пн=0), время "ЧЧ:ММ" и часовой пояс IANA ("" — время сервера)
+	ReportWeekly        bool       `gorm:"not null;default:true"`
+	ReportMonthly       bool       `gorm:"not null;default:false"`
+	ReportWeekday       int        `gorm:"not null;default:0"`
+	ReportTime          string     `gorm:"not null;default:'07:00'"`
+	Timezone            string     `gorm:"not null;default:''"`
+	NextReportAt        *time.Time `gorm:"index"` // когда отправить следующий недельный отчёт
+	NextMonthlyReportAt *time.Time `gorm:"index"` // когда отправить следующий месячный отчёт
+	// Секрет ссылки подписки на календарь; пусто — ссылка ещё не выдавалась
+	CalendarToken   *string          `gorm:"uniqueIndex"`
+	Supplements     []Supplement     `gorm:"constraint:OnDelete:CASCADE"`
+	IntakeLogAudits []IntakeLogAudit `gorm:"constraint:OnDelete:CASCADE"`
+	Achievements    []Achievement    `gorm:"constraint:OnDelete:CASCADE"`
+	StreakFreezes   []StreakFreeze   `gorm:"constraint:OnDelete:CASCADE"`
 }
 
 func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
@@ -0,0 +1,96 @@
+package schedule
+
+import (
+	"DailyDoseBot/internal/models"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Расписание приёма добавки: в какие дни и в какое время ожидается доза.
+// Все напоминания, /log, /status и статистика считают план отсюда,
+// чтобы правила (дни недели, даты начала и окончания, приём по необходимости) не расходились.
+
+// Ожидаемая доза: добавка, день и время по расписанию
+type Occurrence struct {
+	SupplementID uuid.UUID
+	Date         time.Time
+	Time         string // "ЧЧ:ММ" или "" — один приём в день без напоминания
+}
+
+// Календарный день момента t в его часовом поясе; даты приёмов хранятся полночью UTC
+func Date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Номер дня недели в нумерации бота: Пн=0 ... Вс=6
+func Weekday(t time.Time) int {
+	weekday := int(t.Weekday())
+	if weekday == 0 {
+		return 6 // Go: Sunday=0, а у нас Вс=6
+	}
+	return weekday - 1 // Go: Monday=1, а у нас Пн=0
+}
+
+// Нужно ли принимать добавку в указанный день
+func DueOn(s models.Supplement, day time.Time) bool {
+	if s.IsPRN() {
+		return false
+	}
+	if s.StartDate.After(day) {
+		return false
+	}
+	if s.EndDate != nil && s.EndDate.Before(day) {
+		return false
+	}
+	if len(s.DaysOfWeek) > 2 {
+		var daysOfWeek []int
+		_ = json.Unmarshal([]byte(s.DaysOfWeek), &daysOfWeek)
+		for _, d := range daysOfWeek {
+			if d == Weekday(day) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Времена приёма добавки; пустая строка — один приём без напоминания
+func Times(s models.Supplement) []string {
+	var times []string
+	if s.ReminderEnabled && len(s.ReminderTimes) > 2 {
+		_ = json.Unmarshal([]byte(s.ReminderTimes), &times)
+	}
+	if len(times) == 0 {
+		return []string{""}
+	}
+	return times
+}
+
+// Ожидаемые дозы добавки с from по to включительно, по дням и времени
+func Expand(s models.Supplement, from, to time.Time) []Occurrence {
+	var occurrences []Occurrence
+	times := Times(s)
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if !DueOn(s, day) {
+			continue
+		}
+		for _, t := range times {
+			occurrences = append(occurrences, Occurrence{SupplementID: s.ID, Date: day, Time: t})
+		}
+	}
+	return occurrences
+}
+
+// Ожидаемые дозы нескольких добавок за период: по дням, внутри дня — в порядке добавок
+func ExpandAll(supplements []models.Supplement, from, to time.Time) []Occurrence {
+	var occurrences []Occurrence
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		for _, s := range supplements {
+			occurrences = append(occurrences, Expand(s, day, day)...)
+		}
+	}
+	return occurrences
+}
@@ -0,0 +1,242 @@
+package schedule
+
+import (
+	"DailyDoseBot/internal/models"
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func datePtr(y int, m time.Month, d int) *time.Time {
+	t := date(y, m, d)
+	return &t
+}
+
+// 2026-10-19 — понедельник
+var monday = date(2026, 10, 19)
+
+func TestWeekday(t *testing.T) {
+	for i := 0; i < 7; i++ {
+		if got := Weekday(monday.AddDate(0, 0, i)); got != i {
+			t.Errorf("Weekday(%s) = %d, want %d", monday.AddDate(0, 0, i).Format("2006-01-02"), got, i)
+		}
+	}
+}
+
+func TestDueOn(t *testing.T) {
+	tests := []struct {
+		name string
+		s    models.Supplement
+		day  time.Time
+		want bool
+	}{
+		{"каждый день без списка дней", models.Supplement{StartDate: monday}, monday, true},
+		{"пустой список дней — каждый день", models.Supplement{StartDate: monday, DaysOfWeek: datatypes.JSON(`[]`)}, monday.AddDate(0, 0, 3), true},
+		{"день из списка", models.Supplement{StartDate: monday, DaysOfWeek: datatypes.JSON(`[0,2,4]`)}, monday.AddDate(0, 0, 2), true},
+		{"день не из списка", models.Supplement{StartDate: monday, DaysOfWeek: datatypes.JSON(`[0,2,4]`)}, monday.AddDate(0, 0, 1), false},
+		{"воскресенье", models.Supplement{StartDate: monday, DaysOfWeek: datatypes.JSON(`[6]`)}, monday.AddDate(0, 0, 6), true},
+		{"битый JSON дней — ни одного дня", models.Supplement{StartDate: monday, DaysOfWeek: datatypes.JSON(`[0,1`)}, monday, false},
+		{"до начала курса", models.Supplement{StartDate: monday}, monday.AddDate(0, 0, -1), false},
+		{"первый день курса", models.Supplement{StartDate: monday, EndDate: datePtr(2026, 10, 25)}, monday, true},
+		{"последний день курса включительно", models.Supplement{StartDate: monday, EndDate: datePtr(2026, 10, 25)}, date(2026, 10, 25), true},
+		{"после окончания курса", models.Supplement{StartDate: monday, EndDate: datePtr(2026, 10, 25)}, date(2026, 10, 26), false},
+		{"по необходимости", models.Supplement{StartDate: monday, ScheduleType: models.SchedulePRN}, monday, false},
+		{"завершённый курс без даты окончания", models.Supplement{StartDate: monday, Completed: true}, monday, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DueOn(tt.s, tt.day); got != tt.want {
+				t.Errorf("DueOn() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimes(t *testing.T) {
+	tests := []struct {
+		name string
+		s    models.Supplement
+		want []string
+	}{
+		{"напоминания включены", models.Supplement{ReminderEnabled: true, ReminderTimes: datatypes.JSON(`["08:00","20:00"]`)}, []string{"08:00", "20:00"}},
+		{"напоминания выключены", models.Supplement{ReminderEnabled: false, ReminderTimes: datatypes.JSON(`["08:00","20:00"]`)}, []string{""}},
+		{"пустой список", models.Supplement{ReminderEnabled: true, ReminderTimes: datatypes.JSON(`[]`)}, []string{""}},
+		{"нет значения", models.Supplement{ReminderEnabled: true}, []string{""}},
+		{"битый JSON", models.Supplement{ReminderEnabled: true, ReminderTimes: datatypes.JSON(`["08:00"`)}, []string{""}},
+		{"не строки", models.Supplement{ReminderEnabled: true, ReminderTimes: datatypes.JSON(`[800]`)}, []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Times(tt.s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Times() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	id := uuid.New()
+	tests := []struct {
+		name     string
+		s        models.Supplement
+		from, to time.Time
+		want     []Occurrence
+	}{
+		{
+			name: "два времени, курс заканчивается внутри периода",
+			s: models.Supplement{ID: id, StartDate: monday, EndDate: datePtr(2026, 10, 20),
+				ReminderEnabled: true, ReminderTimes: datatypes.JSON(`["08:00","20:00"]`)},
+			from: monday.AddDate(0, 0, -1), to: monday.AddDate(0, 0, 3),
+			want: []Occurrence{
+				{id, monday, "08:00"}, {id, monday, "20:00"},
+				{id, date(2026, 10, 20), "08:00"}, {id, date(2026, 10, 20), "20:00"},
+			},
+		},
+		{
+			name: "без напоминаний — один приём в нужные дни",
+			s:    models.Supplement{ID: id, StartDate: monday, DaysOfWeek: datatypes.JSON(`[1,3]`), ReminderTimes: datatypes.JSON(`["09:00"]`)},
+			from: monday, to: monday.AddDate(0, 0, 6),
+			want: []Occurrence{{id, date(2026, 10, 20), ""}, {id, date(2026, 10, 22), ""}},
+		},
+		{
+			name: "по необходимости",
+			s:    models.Supplement{ID: id, StartDate: monday, ScheduleType: models.SchedulePRN, ReminderEnabled: true, ReminderTimes: datatypes.JSON(`["09:00"]`)},
+			from: monday, to: monday.AddDate(0, 0, 6),
+			want: nil,
+		},
+		{
+			name: "пустой период",
+			s:    models.Supplement{ID: id, StartDate: monday},
+			from: monday, to: monday.AddDate(0, 0, -1),
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Expand(tt.s, tt.from, tt.to); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expand() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandAllOrder(t *testing.T) {
+	a := models.Supplement{ID: uuid.New(), StartDate: monday, ReminderEnabled: true, ReminderTimes: datatypes.JSON(`["20:00"]`)}
+	b := models.Supplement{ID: uuid.New(), StartDate: monday, ReminderEnabled: true, ReminderTimes: datatypes.JSON(`["08:00"]`)}
+	tuesday := monday.AddDate(0, 0, 1)
+	want := []Occurrence{
+		{a.ID, monday, "20:00"}, {b.ID, monday, "08:00"},
+		{a.ID, tuesday, "20:00"}, {b.ID, tuesday, "08:00"},
+	}
+	if got := ExpandAll([]models.Supplement{a, b}, monday, tuesday); !reflect.DeepEqual(got, want) {
+		t.Errorf("ExpandAll() = %v, want %v", got, want)
+	}
+}
+
+// Случайная добавка и период для проверки свойств
+type expandCase struct {
+	S        models.Supplement
+	From, To time.Time
+	Split    time.Time // день внутри периода
+}
+
+func (expandCase) Generate(r *rand.Rand, _ int) reflect.Value {
+	base := monday.AddDate(0, 0, r.Intn(60)-30)
+	s := models.Supplement{ID: uuid.New(), StartDate: base.AddDate(0, 0, r.Intn(20)-10)}
+	if r.Intn(2) == 0 {
+		s.EndDate = datePtr(s.StartDate.Year(), s.StartDate.Month(), s.StartDate.Day()+r.Intn(30))
+	}
+	if r.Intn(5) == 0 {
+		s.ScheduleType = models.SchedulePRN
+	}
+	if r.Intn(3) > 0 {
+		var days []int
+		for d := 0; d < 7; d++ {
+			if r.Intn(2) == 0 {
+				days = append(days, d)
+			}
+		}
+		raw, _ := json.Marshal(days)
+		s.DaysOfWeek = datatypes.JSON(raw)
+	}
+	s.ReminderEnabled = r.Intn(2) == 0
+	s.ReminderTimes = datatypes.JSON([]string{`[]`, `["08:00"]`, `["08:00","14:30","21:00"]`}[r.Intn(3)])
+	from := base.AddDate(0, 0, r.Intn(10)-5)
+	length := r.Intn(40)
+	return reflect.ValueOf(expandCase{
+		S:     s,
+		From:  from,
+		To:    from.AddDate(0, 0, length),
+		Split: from.AddDate(0, 0, r.Intn(length+1)),
+	})
+}
+
+// Разбиение периода на две части не меняет набор доз
+func TestExpandSplitProperty(t *testing.T) {
+	f := func(c expandCase) bool {
+		whole := Expand(c.S, c.From, c.To)
+		parts := append(Expand(c.S, c.From, c.Split), Expand(c.S, c.Split.AddDate(0, 0, 1), c.To)...)
+		return reflect.DeepEqual(whole, parts) || (len(whole) == 0 && len(parts) == 0)
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+// Ни одна доза не выпадает за пределы курса, периода или на выключенный день недели
+func TestExpandBoundsProperty(t *testing.T) {
+	f := func(c expandCase) bool {
+		var days []int
+		_ = json.Unmarshal(c.S.DaysOfWeek, &days)
+		allowed := make(map[int]bool)
+		for _, d := range days {
+			allowed[d] = true
+		}
+		for _, occ := range Expand(c.S, c.From, c.To) {
+			if c.S.IsPRN() || occ.SupplementID != c.S.ID {
+				return false
+			}
+			if occ.Date.Before(c.From) || occ.Date.After(c.To) || occ.Date.Before(c.S.StartDate) {
+				return false
+			}
+			if c.S.EndDate != nil && occ.Date.After(*c.S.EndDate) {
+				return false
+			}
+			if len(days) > 0 && !allowed[Weekday(occ.Date)] {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDate(t *testing.T) {
+	msk := time.FixedZone("UTC+3", 3*60*60)
+	pst := time.FixedZone("UTC-8", -8*60*60)
+	tests := []struct {
+		in   time.Time
+		want time.Time
+	}{
+		{time.Date(2026, 10, 20, 1, 30, 0, 0, msk), date(2026, 10, 20)},  // в UTC ещё 19-е
+		{time.Date(2026, 10, 19, 23, 30, 0, 0, pst), date(2026, 10, 19)}, // в UTC уже 20-е
+		{time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), date(2026, 10, 19)},
+	}
+	for _, tt := range tests {
+		if got := Date(tt.in); !got.Equal(tt.want) || got.Location() != time.UTC {
+			t.Errorf("Date(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
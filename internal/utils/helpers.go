@@ -57,9 +57,22 @@ func SendMainMenu(c tele.Context) error {
 
 <b>Команды:</b>
 /add — добавить новую добавку
+/bulk — добавить несколько добавок списком или CSV-файлом
 /list — список всех добавок
-/log — отметить приём вручную
+/log — отметить приём вручную (в том числе за прошлые дни)
 /status — статус и прогресс за сегодня
+/timing — насколько вовремя ты принимаешь добавки
+/journal — заметки и побочные эффекты
+/stats — статистика за неделю, месяц или свой период
+/achievements — достижения и серии
+/analysis — когда и почему пропускаются приёмы
+/report — когда присылать недельный и месячный отчёты
+/doctor — отчёт для врача за выбранный период
+/export — выгрузить историю и добавки в CSV
+/calendar — расписание приёма для календаря (.ics)
+/export_all — полная выгрузка аккаунта в JSON, /import — загрузка из неё
+/deleteme — удалить аккаунт и все данные
+/took — отметить приём добавки «по необходимости»
 /help — показать это сообщение
 `
 	return c.Send(msg, &tele.SendOptions{ParseMode: tele.ModeHTML})